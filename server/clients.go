@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// У каждого WebSocket клиента своя очередь и своя горутина записи. Рассылка
+// только раскладывает сообщение по очередям и никогда не ждет сеть, а клиента,
+// чья очередь переполнилась, отключаем: пусть переподключится и получит state.
+
+const (
+	// Сколько сообщений ждут отправки, прежде чем клиента сочтут медленным
+	clientSendBuffer = 256
+	// Сколько ждем записи одного сообщения
+	clientWriteWait = 10 * time.Second
+	// Клиент без ответа на ping дольше pongWait считается отвалившимся
+	clientPongWait   = 60 * time.Second
+	clientPingPeriod = clientPongWait * 9 / 10
+	// Клиенты нам ничего содержательного не пишут
+	clientMaxMessageSize = 4096
+)
+
+type client struct {
+	conn      *websocket.Conn
+	send      chan []byte
+	closeOnce sync.Once
+}
+
+func newClient(conn *websocket.Conn) *client {
+	return &client{
+		conn: conn,
+		send: make(chan []byte, clientSendBuffer),
+	}
+}
+
+// close закрывает очередь: writer отправляет close и закрывает соединение.
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.send)
+	})
+}
+
+func (c *client) writer() {
+	ticker := time.NewTicker(clientPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(clientWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				log.Printf("WebSocket error: %v", err)
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(clientWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// broadcast кладет сообщение в очередь каждого клиента.
+func (gs *GameState) broadcast(message []byte) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	for c := range gs.clients {
+		select {
+		case c.send <- message:
+		default:
+			log.Printf("WebSocket client %s is too slow, disconnecting", c.conn.RemoteAddr())
+			delete(gs.clients, c)
+			c.close()
+		}
+	}
+}
+
+func (gs *GameState) removeClient(c *client) {
+	gs.mu.Lock()
+	delete(gs.clients, c)
+	gs.mu.Unlock()
+	c.close()
+}
+
+// closeClients отключает всех клиентов закрытой комнаты.
+func (gs *GameState) closeClients() {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	for c := range gs.clients {
+		delete(gs.clients, c)
+		c.close()
+	}
+}
+
+// handleWebSocket подключает игрока: токен сессии приходит параметром token.
+func (room *Room) handleWebSocket(w http.ResponseWriter, r *http.Request, player int) {
+	conn, err := room.gameState.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+	log.Printf("[%s] player %d connected", room.code, player)
+
+	c := newClient(conn)
+	go c.writer()
+	// комната считается брошенной через roomTTL после ухода последнего клиента
+	defer room.touch()
+	defer room.gameState.removeClient(c)
+
+	// Вместо /actualize клиент сразу получает все состояние игры. Оно встает
+	// в очередь первым, в игровом цикле, поэтому все, что разослано позже,
+	// придет после него.
+	room.do(func() {
+		data, _ := json.Marshal(room.stateMessage())
+		c.send <- data
+
+		room.gameState.mu.Lock()
+		room.gameState.clients[c] = true
+		room.gameState.mu.Unlock()
+	})
+
+	conn.SetReadLimit(clientMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(clientPongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(clientPongWait))
+		return nil
+	})
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
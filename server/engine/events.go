@@ -0,0 +1,86 @@
+package engine
+
+// Event - то, что произошло в игре и о чем надо сообщить клиентам.
+type Event interface {
+	event()
+}
+
+// Started - игра началась, ждем подтверждений старта.
+type Started struct {
+	FirstPlayer int
+	Players     []int
+}
+
+// TurnStarted - игрок PlayerId выбирает вопрос в раунде Round.
+type TurnStarted struct {
+	Round    int
+	PlayerId int
+}
+
+type RoundStarted struct {
+	Round int
+}
+
+type QuestionSelected struct {
+	QuestionId string
+	PlayerId   int
+	Price      int
+}
+
+// BuzzerOpened - все живые игроки прочитали вопрос, можно жать "ответить".
+type BuzzerOpened struct {
+	QuestionId string
+}
+
+// Buzzed - игрок нажал "ответить". First - нажатие открыло окно выбора
+// отвечающего, по его окончании вызывающий делает ResolveBuzz.
+type Buzzed struct {
+	PlayerId int
+	First    bool
+}
+
+// AnswerTurn - игрок PlayerId получил право ответа.
+type AnswerTurn struct {
+	PlayerId   int
+	QuestionId string
+}
+
+// ClarifyRequested - ответ неполный, ждем уточнения от того же игрока.
+type ClarifyRequested struct {
+	PlayerId   int
+	QuestionId string
+}
+
+type AnswerJudged struct {
+	PlayerId    int
+	QuestionId  string
+	Correct     bool
+	ScoreChange int
+	// Unfreeze - больше никто не ждет ответа, таймер вопроса идет дальше
+	Unfreeze bool
+}
+
+type NobodyAnswered struct {
+	QuestionId string
+}
+
+// AnswerRevealed - показан правильный ответ. После паузы вызывающий
+// делает NextTurn.
+type AnswerRevealed struct {
+	QuestionId string
+}
+
+type GameOver struct{}
+
+func (Started) event()          {}
+func (TurnStarted) event()      {}
+func (RoundStarted) event()     {}
+func (QuestionSelected) event() {}
+func (BuzzerOpened) event()     {}
+func (Buzzed) event()           {}
+func (AnswerTurn) event()       {}
+func (ClarifyRequested) event() {}
+func (AnswerJudged) event()     {}
+func (NobodyAnswered) event()   {}
+func (AnswerRevealed) event()   {}
+func (GameOver) event()         {}
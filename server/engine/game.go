@@ -0,0 +1,484 @@
+// Package engine - правила игры без HTTP, WebSocket и модели: кто выбирает
+// вопрос, кто отвечает, сколько очков за ответ и когда менять раунд.
+//
+// Команды меняют состояние Game и возвращают события, которые сервер
+// рассылает клиентам. Таймеры, проверку ответов и реплики ведущего ведет
+// вызывающая сторона: по событиям она решает, когда вызвать следующую команду.
+// Game не потокобезопасен, вызывающий держит свою блокировку.
+//
+// Фазы меняются только по таблице переходов из machine.go. Команда не
+// в свое время возвращает *TransitionError, невыполненное условие - *GuardError.
+package engine
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+)
+
+type Phase string
+
+const (
+	PhaseJoining        Phase = "joining"
+	PhaseStartAck       Phase = "wait-start-ack"
+	PhaseSelectQuestion Phase = "select-question"
+	PhaseQuestion       Phase = "question"
+	PhaseWaitAnswer     Phase = "wait-answer"
+	PhaseShowAnswer     Phase = "show-answer"
+	PhaseGameOver       Phase = "game-over"
+)
+
+// Role - кто игрок за столом. Номера игрокам выдает вызывающий, о роли
+// номер ничего не говорит.
+type Role string
+
+const (
+	RolePlayer    Role = "player"    // живой игрок
+	RoleNPC       Role = "npc"       // игрок, за которого отвечает модель
+	RoleHost      Role = "host"      // ведущий, в розыгрыше не участвует
+	RoleSpectator Role = "spectator" // зритель, в розыгрыше не участвует
+)
+
+var (
+	ErrWrongPhase      = errors.New("not allowed in this game phase")
+	ErrNoPlayers       = errors.New("no eligible players")
+	ErrUnknownPlayer   = errors.New("player not found")
+	ErrPlayerExists    = errors.New("player id already taken")
+	ErrNPC             = errors.New("not allowed for NPC players")
+	ErrNotYourTurn     = errors.New("not current player")
+	ErrUnknownQuestion = errors.New("question not found")
+	ErrAnswered        = errors.New("question already answered")
+	ErrAlreadyTried    = errors.New("player already tried to answer")
+)
+
+type Player struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	Role  Role   `json:"role"`
+}
+
+// Contestant - игрок разыгрывает вопросы: живой или NPC.
+func (p *Player) Contestant() bool {
+	return p.Role == RolePlayer || p.Role == RoleNPC
+}
+
+// live - живой игрок: его ждут подтверждения старта, показа вопроса и нажатий.
+func (p *Player) live() bool {
+	return p.Role == RolePlayer
+}
+
+// Board - сетка вопросов пакета: элемент на раунд, в нем цены вопросов
+// по их id "раунд_тема_вопрос".
+type Board []map[string]int
+
+// Verdict - решение по ответу, принятое снаружи (моделью или локально).
+type Verdict struct {
+	Correct bool
+	// Clarify - ответ неполный, живой игрок может один раз его уточнить
+	Clarify bool
+}
+
+type Game struct {
+	// Rand выбирает случайный индекс из [0, n): первого игрока и того,
+	// кто отвечает из нажавших. В тестах его можно подменить.
+	Rand func(n int) int
+
+	phase      Phase
+	board      Board
+	players    map[int]*Player
+	current    int
+	round      int
+	answered   map[string]bool
+	question   string
+	acks       map[int]bool
+	shown      map[int]bool
+	opened     bool
+	buzzes     []int
+	tried      []int
+	answering  int
+	clarifying bool
+}
+
+func NewGame(board Board) *Game {
+	return &Game{
+		Rand:     rand.Intn,
+		phase:    PhaseJoining,
+		board:    board,
+		players:  make(map[int]*Player),
+		answered: make(map[string]bool),
+		acks:     make(map[int]bool),
+		shown:    make(map[int]bool),
+	}
+}
+
+func (g *Game) Phase() Phase            { return g.phase }
+func (g *Game) Round() int              { return g.round }
+func (g *Game) CurrentPlayer() int      { return g.current }
+func (g *Game) Question() string        { return g.question }
+func (g *Game) Answering() int          { return g.answering }
+func (g *Game) Clarifying() bool        { return g.clarifying }
+func (g *Game) Answered(id string) bool { return g.answered[id] }
+func (g *Game) Opened() bool            { return g.opened }
+
+// Buzzing - кто-то нажал "ответить" и ждет ResolveBuzz.
+func (g *Game) Buzzing() bool {
+	return len(g.buzzes) > 0
+}
+
+// Tried - кто уже нажимал "ответить" или отказался от текущего вопроса.
+func (g *Game) Tried() []int {
+	return append([]int(nil), g.tried...)
+}
+
+// OpenQuestions - несыгранные вопросы текущего раунда, от дешевых к дорогим.
+func (g *Game) OpenQuestions() []string {
+	if g.round < 1 || g.round > len(g.board) {
+		return nil
+	}
+	prices := g.board[g.round-1]
+	ids := make([]string, 0, len(prices))
+	for id := range prices {
+		if !g.answered[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if prices[ids[i]] != prices[ids[j]] {
+			return prices[ids[i]] < prices[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+func (g *Game) Player(id int) *Player {
+	return g.players[id]
+}
+
+// Load задает сетку вопросов. Пакет можно сменить только до старта.
+func (g *Game) Load(board Board) error {
+	if err := g.Can(TriggerLoad); err != nil {
+		return err
+	}
+	g.board = board
+	return nil
+}
+
+// Join добавляет игрока. Занятый номер - ошибка: игрока с ним не заменить.
+func (g *Game) Join(p *Player) error {
+	if err := g.Can(TriggerJoin); err != nil {
+		return err
+	}
+	if _, taken := g.players[p.ID]; taken {
+		return ErrPlayerExists
+	}
+	g.players[p.ID] = p
+	return nil
+}
+
+func (g *Game) Leave(id int) error {
+	if err := g.Can(TriggerLeave); err != nil {
+		return err
+	}
+	delete(g.players, id)
+	return nil
+}
+
+// Start выбирает случайного первого игрока из живых (из NPC, только если
+// за столом одни NPC) и ждет, пока живые игроки подтвердят старт
+// (AcknowledgeStart) или выйдет время (StartTimeout).
+func (g *Game) Start() ([]Event, error) {
+	return g.fire(TriggerStart)
+}
+
+func (g *Game) AcknowledgeStart(playerId int) ([]Event, error) {
+	if err := g.Can(TriggerAcknowledgeStart); err != nil {
+		return nil, err
+	}
+
+	player, ok := g.players[playerId]
+	if !ok {
+		return nil, ErrUnknownPlayer
+	}
+	if !player.live() {
+		return nil, ErrNPC
+	}
+
+	g.acks[playerId] = true
+	return g.fire(TriggerAcknowledgeStart)
+}
+
+// StartTimeout начинает игру, не дождавшись подтверждений от всех.
+// Как и у остальных команд таймеров, ошибка перехода значит, что таймер
+// устарел, и молча игнорируется.
+func (g *Game) StartTimeout() []Event {
+	events, _ := g.fire(TriggerStartTimeout)
+	return events
+}
+
+func (g *Game) SelectQuestion(playerId int, questionId string) ([]Event, error) {
+	if err := g.Can(TriggerSelectQuestion); err != nil {
+		return nil, err
+	}
+	if playerId != g.current {
+		return nil, ErrNotYourTurn
+	}
+	if g.answered[questionId] {
+		return nil, ErrAnswered
+	}
+	if _, ok := g.price(questionId); !ok {
+		return nil, ErrUnknownQuestion
+	}
+
+	g.question = questionId
+	return g.fire(TriggerSelectQuestion)
+}
+
+// QuestionShown отмечает, что игрок дочитал вопрос. Когда дочитали все
+// живые игроки, открывается кнопка "ответить".
+func (g *Game) QuestionShown(playerId int) []Event {
+	if g.Can(TriggerQuestionShown) != nil || g.opened {
+		return nil
+	}
+
+	g.shown[playerId] = true
+	for id, p := range g.players {
+		if p.live() && !g.shown[id] {
+			return nil
+		}
+	}
+
+	g.opened = true
+	return []Event{BuzzerOpened{QuestionId: g.question}}
+}
+
+// Buzz - игрок нажал "ответить". Первое нажатие открывает окно, по
+// истечении которого вызывающий делает ResolveBuzz.
+func (g *Game) Buzz(playerId int) ([]Event, error) {
+	if err := g.Can(TriggerBuzz); err != nil {
+		return nil, err
+	}
+	player, ok := g.players[playerId]
+	if !ok || !player.Contestant() {
+		return nil, ErrUnknownPlayer
+	}
+	if contains(g.tried, playerId) {
+		return nil, ErrAlreadyTried
+	}
+
+	g.buzzes = append(g.buzzes, playerId)
+	g.tried = append(g.tried, playerId)
+
+	return []Event{Buzzed{PlayerId: playerId, First: len(g.buzzes) == 1}}, nil
+}
+
+// ResolveBuzz отдает право ответа случайному из нажавших.
+func (g *Game) ResolveBuzz() []Event {
+	events, _ := g.fire(TriggerResolveBuzz)
+	return events
+}
+
+// SubmitAnswer начисляет очки по вердикту и решает, кто отвечает дальше.
+func (g *Game) SubmitAnswer(playerId int, questionId string, verdict Verdict) ([]Event, error) {
+	trigger := TriggerAnswerWrong
+	if verdict.Correct {
+		trigger = TriggerAnswerCorrect
+	}
+	if err := g.Can(trigger); err != nil {
+		return nil, err
+	}
+	if questionId != g.question {
+		return nil, ErrUnknownQuestion
+	}
+	if playerId != g.answering {
+		return nil, ErrNotYourTurn
+	}
+	player := g.players[playerId]
+	if player == nil {
+		return nil, ErrUnknownPlayer
+	}
+
+	// уточнить можно один раз, NPC уточнять не умеют
+	if verdict.Clarify && !verdict.Correct && !g.clarifying && player.live() {
+		if _, err := g.fire(TriggerClarify); err != nil {
+			return nil, err
+		}
+		g.clarifying = true
+		return []Event{ClarifyRequested{PlayerId: playerId, QuestionId: questionId}}, nil
+	}
+
+	price, _ := g.price(questionId)
+	scoreChange := -price
+	if verdict.Correct {
+		scoreChange = price
+		g.current = playerId
+	}
+	player.Score += scoreChange
+
+	events := []Event{AnswerJudged{
+		PlayerId:    playerId,
+		QuestionId:  questionId,
+		Correct:     verdict.Correct,
+		ScoreChange: scoreChange,
+		Unfreeze:    len(g.buzzes) == 0,
+	}}
+
+	next, err := g.fire(trigger)
+	if err != nil {
+		return nil, err
+	}
+	events = append(events, next...)
+
+	// остались нажавшие на кнопку - сразу отдаем право ответа следующему
+	if g.phase == PhaseQuestion && len(g.buzzes) > 0 {
+		events = append(events, g.ResolveBuzz()...)
+	}
+	return events, nil
+}
+
+// Pass - у игрока вышло время на вопрос. Когда отказались или ответили
+// все живые игроки, показывается правильный ответ.
+func (g *Game) Pass(playerId int) []Event {
+	if g.Can(TriggerPass) != nil {
+		return nil
+	}
+
+	if !contains(g.tried, playerId) {
+		g.tried = append(g.tried, playerId)
+	}
+	events, _ := g.fire(TriggerPass)
+	return events
+}
+
+// NextTurn после показа ответа возвращает к выбору вопроса, а если
+// раунд сыгран - переходит к следующему или заканчивает игру.
+func (g *Game) NextTurn() []Event {
+	events, _ := g.fire(TriggerNextTurn)
+	return events
+}
+
+// AdjustScore меняет очки в обход правил, например после апелляции.
+func (g *Game) AdjustScore(playerId int, delta int) error {
+	player, ok := g.players[playerId]
+	if !ok {
+		return ErrUnknownPlayer
+	}
+	player.Score += delta
+	return nil
+}
+
+// Действия переходов и фаз, их вызывает только move.
+
+func (g *Game) start() []Event {
+	ids := g.contestants()
+	first := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if g.players[id].live() {
+			first = append(first, id)
+		}
+	}
+	if len(first) == 0 {
+		first = ids
+	}
+	g.current = first[g.Rand(len(first))]
+	g.round = 1
+	return []Event{Started{FirstPlayer: g.current, Players: ids}}
+}
+
+func (g *Game) beginTurn() []Event {
+	return []Event{TurnStarted{Round: g.round, PlayerId: g.current}}
+}
+
+func (g *Game) selectQuestion() []Event {
+	g.shown = make(map[int]bool)
+	g.opened = false
+	g.buzzes = nil
+	g.tried = nil
+	g.answering = 0
+	g.clarifying = false
+
+	price, _ := g.price(g.question)
+	return []Event{QuestionSelected{QuestionId: g.question, PlayerId: g.current, Price: price}}
+}
+
+func (g *Game) pickAnswering() []Event {
+	idx := g.Rand(len(g.buzzes))
+	g.answering = g.buzzes[idx]
+	g.buzzes = append(g.buzzes[:idx], g.buzzes[idx+1:]...)
+	return []Event{AnswerTurn{PlayerId: g.answering, QuestionId: g.question}}
+}
+
+func (g *Game) nobodyAnswered() []Event {
+	return []Event{NobodyAnswered{QuestionId: g.question}}
+}
+
+func (g *Game) reveal() []Event {
+	g.answered[g.question] = true
+	return []Event{AnswerRevealed{QuestionId: g.question}}
+}
+
+func (g *Game) nextRound() []Event {
+	g.round++
+	return []Event{RoundStarted{Round: g.round}}
+}
+
+func (g *Game) allAcknowledged() bool {
+	for id, p := range g.players {
+		if p.live() && !g.acks[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Game) price(questionId string) (int, bool) {
+	if g.round < 1 || g.round > len(g.board) {
+		return 0, false
+	}
+	price, ok := g.board[g.round-1][questionId]
+	return price, ok
+}
+
+func (g *Game) roundIsOver() bool {
+	if g.round < 1 || g.round > len(g.board) {
+		return true
+	}
+	for id := range g.board[g.round-1] {
+		if !g.answered[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// allTried - все живые игроки уже нажимали кнопку или отказались.
+// NPC тоже попадают в tried, но ждем мы только живых.
+func (g *Game) allTried() bool {
+	for id, p := range g.players {
+		if p.live() && !contains(g.tried, id) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Game) contestants() []int {
+	ids := make([]int, 0, len(g.players))
+	for id, p := range g.players {
+		if p.Contestant() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func contains(xs []int, x int) bool {
+	for _, v := range xs {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Trigger - команда или таймер, который может сменить фазу игры.
+type Trigger string
+
+const (
+	TriggerLoad             Trigger = "load"
+	TriggerJoin             Trigger = "join"
+	TriggerLeave            Trigger = "leave"
+	TriggerStart            Trigger = "start"
+	TriggerAcknowledgeStart Trigger = "acknowledge-start"
+	TriggerStartTimeout     Trigger = "start-timeout"
+	TriggerSelectQuestion   Trigger = "select-question"
+	TriggerQuestionShown    Trigger = "question-shown"
+	TriggerBuzz             Trigger = "buzz"
+	TriggerResolveBuzz      Trigger = "resolve-buzz"
+	TriggerClarify          Trigger = "clarify"
+	TriggerAnswerCorrect    Trigger = "answer-correct"
+	TriggerAnswerWrong      Trigger = "answer-wrong"
+	TriggerPass             Trigger = "pass"
+	TriggerNextTurn         Trigger = "next-turn"
+)
+
+var ErrGuard = errors.New("transition guard failed")
+
+// TransitionError - в таблице нет перехода по команде из текущей фазы.
+type TransitionError struct {
+	Phase   Phase
+	Trigger Trigger
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s is not allowed in phase %s", e.Trigger, e.Phase)
+}
+
+func (e *TransitionError) Unwrap() error { return ErrWrongPhase }
+
+// GuardError - переходы по команде есть, но ни одно условие не выполнено.
+type GuardError struct {
+	Phase   Phase
+	Trigger Trigger
+	Guard   string
+	Err     error
+}
+
+func (e *GuardError) Error() string {
+	return fmt.Sprintf("%s in phase %s: %s (%s)", e.Trigger, e.Phase, e.Err, e.Guard)
+}
+
+func (e *GuardError) Unwrap() error { return e.Err }
+
+type guard struct {
+	name string
+	ok   func(g *Game) bool
+	// err возвращается, если условие не выполнено, по умолчанию ErrGuard
+	err error
+}
+
+// transition - строка таблицы переходов. Переходы с одинаковыми from и
+// trigger проверяются по порядку, срабатывает первый с выполненным условием.
+type transition struct {
+	from    Phase
+	trigger Trigger
+	to      Phase
+	guard   *guard
+	// action выполняется между выходом из from и входом в to
+	action func(g *Game) []Event
+	// emits - события перехода для диаграммы, включая те, что
+	// возвращает сама команда
+	emits string
+}
+
+// phaseActions - действия при входе в фазу и выходе из нее. Переход в ту же
+// фазу их не вызывает.
+type phaseActions struct {
+	enter, exit func(g *Game) []Event
+	// для диаграммы
+	enterDoc, exitDoc string
+}
+
+var (
+	hasContestants  = &guard{"has players", func(g *Game) bool { return len(g.contestants()) > 0 }, ErrNoPlayers}
+	allAcknowledged = &guard{"all acknowledged", (*Game).allAcknowledged, nil}
+	hasBuzzes       = &guard{"has buzzes", func(g *Game) bool { return len(g.buzzes) > 0 }, nil}
+	allTried        = &guard{"all tried", (*Game).allTried, nil}
+	othersMayAnswer = &guard{"others may answer", func(g *Game) bool { return len(g.buzzes) > 0 || !g.allTried() }, nil}
+	roundGoesOn     = &guard{"round goes on", func(g *Game) bool { return !g.roundIsOver() }, nil}
+	hasNextRound    = &guard{"next round", func(g *Game) bool { return g.roundIsOver() && g.round < len(g.board) }, nil}
+)
+
+// phases перечислены в порядке игры, в нем же они идут на диаграмме.
+var phases = []Phase{
+	PhaseJoining,
+	PhaseStartAck,
+	PhaseSelectQuestion,
+	PhaseQuestion,
+	PhaseWaitAnswer,
+	PhaseShowAnswer,
+	PhaseGameOver,
+}
+
+var actions = map[Phase]phaseActions{
+	PhaseStartAck: {
+		enter:    func(g *Game) []Event { g.acks = make(map[int]bool); return nil },
+		enterDoc: "reset acks",
+	},
+	PhaseSelectQuestion: {
+		enter:    (*Game).beginTurn,
+		enterDoc: "TurnStarted",
+	},
+	PhaseWaitAnswer: {
+		enter:    (*Game).pickAnswering,
+		enterDoc: "AnswerTurn",
+		exit:     func(g *Game) []Event { g.clarifying = false; return nil },
+		exitDoc:  "end clarification",
+	},
+	PhaseShowAnswer: {
+		enter:    (*Game).reveal,
+		enterDoc: "AnswerRevealed",
+	},
+	PhaseGameOver: {
+		enter:    func(g *Game) []Event { return []Event{GameOver{}} },
+		enterDoc: "GameOver",
+	},
+}
+
+var transitions = []transition{
+	{from: PhaseJoining, trigger: TriggerLoad, to: PhaseJoining},
+	{from: PhaseJoining, trigger: TriggerJoin, to: PhaseJoining},
+	{from: PhaseJoining, trigger: TriggerLeave, to: PhaseJoining},
+	{from: PhaseJoining, trigger: TriggerStart, to: PhaseStartAck, guard: hasContestants, action: (*Game).start, emits: "Started"},
+
+	{from: PhaseStartAck, trigger: TriggerAcknowledgeStart, to: PhaseSelectQuestion, guard: allAcknowledged},
+	{from: PhaseStartAck, trigger: TriggerAcknowledgeStart, to: PhaseStartAck},
+	{from: PhaseStartAck, trigger: TriggerStartTimeout, to: PhaseSelectQuestion},
+
+	{from: PhaseSelectQuestion, trigger: TriggerSelectQuestion, to: PhaseQuestion, action: (*Game).selectQuestion, emits: "QuestionSelected"},
+
+	{from: PhaseQuestion, trigger: TriggerQuestionShown, to: PhaseQuestion, emits: "BuzzerOpened"},
+	{from: PhaseQuestion, trigger: TriggerBuzz, to: PhaseQuestion, emits: "Buzzed"},
+	{from: PhaseQuestion, trigger: TriggerResolveBuzz, to: PhaseWaitAnswer, guard: hasBuzzes},
+	{from: PhaseQuestion, trigger: TriggerPass, to: PhaseShowAnswer, guard: allTried, action: (*Game).nobodyAnswered, emits: "NobodyAnswered"},
+	{from: PhaseQuestion, trigger: TriggerPass, to: PhaseQuestion},
+
+	{from: PhaseWaitAnswer, trigger: TriggerClarify, to: PhaseWaitAnswer, emits: "ClarifyRequested"},
+	{from: PhaseWaitAnswer, trigger: TriggerAnswerCorrect, to: PhaseShowAnswer, emits: "AnswerJudged"},
+	{from: PhaseWaitAnswer, trigger: TriggerAnswerWrong, to: PhaseQuestion, guard: othersMayAnswer, emits: "AnswerJudged"},
+	{from: PhaseWaitAnswer, trigger: TriggerAnswerWrong, to: PhaseShowAnswer, emits: "AnswerJudged"},
+	{from: PhaseWaitAnswer, trigger: TriggerPass, to: PhaseShowAnswer, guard: allTried},
+	{from: PhaseWaitAnswer, trigger: TriggerPass, to: PhaseWaitAnswer},
+
+	{from: PhaseShowAnswer, trigger: TriggerNextTurn, to: PhaseSelectQuestion, guard: roundGoesOn},
+	{from: PhaseShowAnswer, trigger: TriggerNextTurn, to: PhaseSelectQuestion, guard: hasNextRound, action: (*Game).nextRound, emits: "RoundStarted"},
+	{from: PhaseShowAnswer, trigger: TriggerNextTurn, to: PhaseGameOver},
+}
+
+// Can проверяет, что команда вообще возможна в текущей фазе. Команды
+// вызывают его до того, как что-то поменять.
+func (g *Game) Can(trigger Trigger) error {
+	for _, t := range transitions {
+		if t.from == g.phase && t.trigger == trigger {
+			return nil
+		}
+	}
+	return &TransitionError{Phase: g.phase, Trigger: trigger}
+}
+
+// fire выполняет первый подходящий переход: выход из фазы, действие
+// перехода, вход в новую фазу.
+func (g *Game) fire(trigger Trigger) ([]Event, error) {
+	var failed *guard
+	for _, t := range transitions {
+		if t.from != g.phase || t.trigger != trigger {
+			continue
+		}
+		if t.guard != nil && !t.guard.ok(g) {
+			failed = t.guard
+			continue
+		}
+		return g.move(t), nil
+	}
+
+	if failed == nil {
+		return nil, &TransitionError{Phase: g.phase, Trigger: trigger}
+	}
+	err := failed.err
+	if err == nil {
+		err = ErrGuard
+	}
+	return nil, &GuardError{Phase: g.phase, Trigger: trigger, Guard: failed.name, Err: err}
+}
+
+func (g *Game) move(t transition) []Event {
+	var events []Event
+	external := t.from != t.to
+
+	if exit := actions[t.from].exit; external && exit != nil {
+		events = append(events, exit(g)...)
+	}
+	if t.action != nil {
+		events = append(events, t.action(g)...)
+	}
+	g.phase = t.to
+	if enter := actions[t.to].enter; external && enter != nil {
+		events = append(events, enter(g)...)
+	}
+	return events
+}
+
+// WriteDot рисует таблицу переходов в формате Graphviz: на стрелках команда,
+// условие и события перехода, в фазах - события при входе и выходе.
+func WriteDot(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph game {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box, style=rounded];\n\n")
+
+	for _, phase := range phases {
+		label := string(phase)
+		if a := actions[phase]; a.enterDoc != "" {
+			label += "\\nentry / " + a.enterDoc
+		}
+		if a := actions[phase]; a.exitDoc != "" {
+			label += "\\nexit / " + a.exitDoc
+		}
+		fmt.Fprintf(&b, "\t%q [label=\"%s\"];\n", phase, label)
+	}
+	b.WriteString("\n")
+
+	for _, t := range transitions {
+		label := string(t.trigger)
+		if t.guard != nil {
+			label += " [" + t.guard.name + "]"
+		}
+		if t.emits != "" {
+			label += "\\n/ " + t.emits
+		}
+		fmt.Fprintf(&b, "\t%q -> %q [label=\"%s\"];\n", t.from, t.to, label)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,580 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testBoard() Board {
+	return Board{
+		{"1_1_1": 100, "1_1_2": 200},
+		{"2_1_1": 300},
+	}
+}
+
+// testPlayers - два живых игрока, NPC и ведущий.
+func testPlayers() []Player {
+	return []Player{
+		{ID: 1, Name: "Аня", Role: RolePlayer},
+		{ID: 2, Name: "Боря", Role: RolePlayer},
+		{ID: 3, Name: "Бот", Role: RoleNPC},
+		{ID: 4, Name: "Ведущий", Role: RoleHost},
+	}
+}
+
+// newTestGame сажает игроков за стол и, если задан снимок, переводит игру
+// в его состояние. Rand всегда выбирает первого.
+func newTestGame(t *testing.T, players []Player, s *Snapshot) *Game {
+	t.Helper()
+	g := NewGame(testBoard())
+	g.Rand = func(int) int { return 0 }
+	for i := range players {
+		p := players[i]
+		if err := g.Join(&p); err != nil {
+			t.Fatalf("Join(%d): %v", p.ID, err)
+		}
+	}
+	if s != nil {
+		snapshot := *s
+		snapshot.Board = testBoard()
+		if err := g.Restore(snapshot); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+	}
+	return g
+}
+
+// Состояния, с которых начинаются переходы из каждой фазы.
+var (
+	startAckState = Snapshot{Phase: PhaseStartAck, Current: 1, Round: 1}
+	selectState   = Snapshot{Phase: PhaseSelectQuestion, Current: 1, Round: 1}
+	questionState = Snapshot{Phase: PhaseQuestion, Current: 1, Round: 1, Question: "1_1_1", Opened: true}
+	waitState     = Snapshot{Phase: PhaseWaitAnswer, Current: 1, Round: 1, Question: "1_1_1", Opened: true, Tried: []int{1}, Answering: 1}
+	showState     = Snapshot{Phase: PhaseShowAnswer, Current: 1, Round: 1, Question: "1_1_1", Answered: map[string]bool{"1_1_1": true}}
+	gameOverState = Snapshot{Phase: PhaseGameOver, Current: 1, Round: 2}
+)
+
+func phaseState(phase Phase) *Snapshot {
+	states := map[Phase]Snapshot{
+		PhaseStartAck:       startAckState,
+		PhaseSelectQuestion: selectState,
+		PhaseQuestion:       questionState,
+		PhaseWaitAnswer:     waitState,
+		PhaseShowAnswer:     showState,
+		PhaseGameOver:       gameOverState,
+	}
+	s, ok := states[phase]
+	if !ok {
+		return nil
+	}
+	return &s
+}
+
+// with возвращает копию снимка, измененную f.
+func with(s Snapshot, f func(s *Snapshot)) *Snapshot {
+	f(&s)
+	return &s
+}
+
+// rowName - строка таблицы переходов так, как ее видно на диаграмме.
+func rowName(t transition) string {
+	name := string(t.from) + " " + string(t.trigger) + " -> " + string(t.to)
+	if t.guard != nil {
+		name += " [" + t.guard.name + "]"
+	}
+	return name
+}
+
+func sameEvents(got, want []Event) bool {
+	if len(got) == 0 && len(want) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func TestTransitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		row      string // строка таблицы переходов, по которой идет команда
+		players  []Player
+		snapshot *Snapshot
+		run      func(g *Game) ([]Event, error)
+		phase    Phase
+		events   []Event
+		check    func(t *testing.T, g *Game)
+	}{
+		{
+			name:  "load package",
+			row:   "joining load -> joining",
+			run:   func(g *Game) ([]Event, error) { return nil, g.Load(testBoard()) },
+			phase: PhaseJoining,
+		},
+		{
+			name:  "join",
+			row:   "joining join -> joining",
+			run:   func(g *Game) ([]Event, error) { return nil, g.Join(&Player{ID: 5, Role: RoleSpectator}) },
+			phase: PhaseJoining,
+		},
+		{
+			name:  "leave",
+			row:   "joining leave -> joining",
+			run:   func(g *Game) ([]Event, error) { return nil, g.Leave(2) },
+			phase: PhaseJoining,
+			check: func(t *testing.T, g *Game) {
+				if g.Player(2) != nil {
+					t.Error("player 2 is still at the table")
+				}
+			},
+		},
+		{
+			name: "start picks a live player",
+			row:  "joining start -> wait-start-ack [has players]",
+			run: func(g *Game) ([]Event, error) {
+				g.Rand = func(n int) int { return n - 1 }
+				return g.Start()
+			},
+			phase:  PhaseStartAck,
+			events: []Event{Started{FirstPlayer: 2, Players: []int{1, 2, 3}}},
+			check: func(t *testing.T, g *Game) {
+				if g.Round() != 1 {
+					t.Errorf("round = %d, want 1", g.Round())
+				}
+			},
+		},
+		{
+			name:    "start with NPCs only",
+			row:     "joining start -> wait-start-ack [has players]",
+			players: []Player{{ID: 3, Role: RoleNPC}, {ID: 4, Role: RoleHost}},
+			run:     (*Game).Start,
+			phase:   PhaseStartAck,
+			events:  []Event{Started{FirstPlayer: 3, Players: []int{3}}},
+		},
+		{
+			name:     "last live player acknowledges start",
+			row:      "wait-start-ack acknowledge-start -> select-question [all acknowledged]",
+			snapshot: with(startAckState, func(s *Snapshot) { s.Acks = map[int]bool{1: true} }),
+			run:      func(g *Game) ([]Event, error) { return g.AcknowledgeStart(2) },
+			phase:    PhaseSelectQuestion,
+			events:   []Event{TurnStarted{Round: 1, PlayerId: 1}},
+		},
+		{
+			name:     "acknowledge start while others have not",
+			row:      "wait-start-ack acknowledge-start -> wait-start-ack",
+			snapshot: &startAckState,
+			run:      func(g *Game) ([]Event, error) { return g.AcknowledgeStart(1) },
+			phase:    PhaseStartAck,
+		},
+		{
+			name:     "start timeout",
+			row:      "wait-start-ack start-timeout -> select-question",
+			snapshot: &startAckState,
+			run:      func(g *Game) ([]Event, error) { return g.StartTimeout(), nil },
+			phase:    PhaseSelectQuestion,
+			events:   []Event{TurnStarted{Round: 1, PlayerId: 1}},
+		},
+		{
+			name:     "select question",
+			row:      "select-question select-question -> question",
+			snapshot: &selectState,
+			run:      func(g *Game) ([]Event, error) { return g.SelectQuestion(1, "1_1_2") },
+			phase:    PhaseQuestion,
+			events:   []Event{QuestionSelected{QuestionId: "1_1_2", PlayerId: 1, Price: 200}},
+		},
+		{
+			name:     "all live players have read the question",
+			row:      "question question-shown -> question",
+			snapshot: with(questionState, func(s *Snapshot) { s.Opened = false }),
+			run: func(g *Game) ([]Event, error) {
+				if events := g.QuestionShown(1); len(events) != 0 {
+					return events, nil
+				}
+				return g.QuestionShown(2), nil
+			},
+			phase:  PhaseQuestion,
+			events: []Event{BuzzerOpened{QuestionId: "1_1_1"}},
+			check: func(t *testing.T, g *Game) {
+				if !g.Opened() {
+					t.Error("buzzer is not open")
+				}
+			},
+		},
+		{
+			name:     "buzz",
+			row:      "question buzz -> question",
+			snapshot: &questionState,
+			run:      func(g *Game) ([]Event, error) { return g.Buzz(3) },
+			phase:    PhaseQuestion,
+			events:   []Event{Buzzed{PlayerId: 3, First: true}},
+		},
+		{
+			name:     "second buzz",
+			row:      "question buzz -> question",
+			snapshot: with(questionState, func(s *Snapshot) { s.Buzzes = []int{3}; s.Tried = []int{3} }),
+			run:      func(g *Game) ([]Event, error) { return g.Buzz(1) },
+			phase:    PhaseQuestion,
+			events:   []Event{Buzzed{PlayerId: 1, First: false}},
+		},
+		{
+			name:     "resolve buzz",
+			row:      "question resolve-buzz -> wait-answer [has buzzes]",
+			snapshot: with(questionState, func(s *Snapshot) { s.Buzzes = []int{2}; s.Tried = []int{2} }),
+			run:      func(g *Game) ([]Event, error) { return g.ResolveBuzz(), nil },
+			phase:    PhaseWaitAnswer,
+			events:   []Event{AnswerTurn{PlayerId: 2, QuestionId: "1_1_1"}},
+			check: func(t *testing.T, g *Game) {
+				if g.Answering() != 2 || g.Buzzing() {
+					t.Errorf("answering = %d, buzzing = %v", g.Answering(), g.Buzzing())
+				}
+			},
+		},
+		{
+			name:     "pass when everyone has tried",
+			row:      "question pass -> show-answer [all tried]",
+			snapshot: with(questionState, func(s *Snapshot) { s.Tried = []int{1} }),
+			run:      func(g *Game) ([]Event, error) { return g.Pass(2), nil },
+			phase:    PhaseShowAnswer,
+			events:   []Event{NobodyAnswered{QuestionId: "1_1_1"}, AnswerRevealed{QuestionId: "1_1_1"}},
+			check: func(t *testing.T, g *Game) {
+				if !g.Answered("1_1_1") {
+					t.Error("question is not marked answered")
+				}
+			},
+		},
+		{
+			name:     "pass while others may still answer",
+			row:      "question pass -> question",
+			snapshot: &questionState,
+			run:      func(g *Game) ([]Event, error) { return g.Pass(1), nil },
+			phase:    PhaseQuestion,
+		},
+		{
+			name:     "clarify",
+			row:      "wait-answer clarify -> wait-answer",
+			snapshot: &waitState,
+			run: func(g *Game) ([]Event, error) {
+				return g.SubmitAnswer(1, "1_1_1", Verdict{Clarify: true})
+			},
+			phase:  PhaseWaitAnswer,
+			events: []Event{ClarifyRequested{PlayerId: 1, QuestionId: "1_1_1"}},
+			check: func(t *testing.T, g *Game) {
+				if !g.Clarifying() || g.Player(1).Score != 0 {
+					t.Errorf("clarifying = %v, score = %d", g.Clarifying(), g.Player(1).Score)
+				}
+			},
+		},
+		{
+			name:     "correct answer",
+			row:      "wait-answer answer-correct -> show-answer",
+			snapshot: with(waitState, func(s *Snapshot) { s.Tried = []int{2}; s.Answering = 2 }),
+			run: func(g *Game) ([]Event, error) {
+				return g.SubmitAnswer(2, "1_1_1", Verdict{Correct: true})
+			},
+			phase: PhaseShowAnswer,
+			events: []Event{
+				AnswerJudged{PlayerId: 2, QuestionId: "1_1_1", Correct: true, ScoreChange: 100, Unfreeze: true},
+				AnswerRevealed{QuestionId: "1_1_1"},
+			},
+			check: func(t *testing.T, g *Game) {
+				if g.CurrentPlayer() != 2 || g.Player(2).Score != 100 {
+					t.Errorf("current = %d, score = %d", g.CurrentPlayer(), g.Player(2).Score)
+				}
+			},
+		},
+		{
+			name:     "wrong answer, others may answer",
+			row:      "wait-answer answer-wrong -> question [others may answer]",
+			snapshot: &waitState,
+			run:      func(g *Game) ([]Event, error) { return g.SubmitAnswer(1, "1_1_1", Verdict{}) },
+			phase:    PhaseQuestion,
+			events: []Event{
+				AnswerJudged{PlayerId: 1, QuestionId: "1_1_1", Correct: false, ScoreChange: -100, Unfreeze: true},
+			},
+			check: func(t *testing.T, g *Game) {
+				if g.Player(1).Score != -100 || g.CurrentPlayer() != 1 {
+					t.Errorf("score = %d, current = %d", g.Player(1).Score, g.CurrentPlayer())
+				}
+			},
+		},
+		{
+			name:     "wrong answer passes the turn to the next buzzer",
+			row:      "wait-answer answer-wrong -> question [others may answer]",
+			snapshot: with(waitState, func(s *Snapshot) { s.Buzzes = []int{2}; s.Tried = []int{1, 2} }),
+			run:      func(g *Game) ([]Event, error) { return g.SubmitAnswer(1, "1_1_1", Verdict{}) },
+			phase:    PhaseWaitAnswer,
+			events: []Event{
+				AnswerJudged{PlayerId: 1, QuestionId: "1_1_1", Correct: false, ScoreChange: -100, Unfreeze: false},
+				AnswerTurn{PlayerId: 2, QuestionId: "1_1_1"},
+			},
+		},
+		{
+			name:     "wrong answer of the last player",
+			row:      "wait-answer answer-wrong -> show-answer",
+			snapshot: with(waitState, func(s *Snapshot) { s.Tried = []int{1, 2}; s.Answering = 2 }),
+			run:      func(g *Game) ([]Event, error) { return g.SubmitAnswer(2, "1_1_1", Verdict{}) },
+			phase:    PhaseShowAnswer,
+			events: []Event{
+				AnswerJudged{PlayerId: 2, QuestionId: "1_1_1", Correct: false, ScoreChange: -100, Unfreeze: true},
+				AnswerRevealed{QuestionId: "1_1_1"},
+			},
+		},
+		{
+			name:     "NPC cannot clarify",
+			row:      "wait-answer answer-wrong -> show-answer",
+			snapshot: with(waitState, func(s *Snapshot) { s.Tried = []int{1, 2, 3}; s.Answering = 3 }),
+			run: func(g *Game) ([]Event, error) {
+				return g.SubmitAnswer(3, "1_1_1", Verdict{Clarify: true})
+			},
+			phase: PhaseShowAnswer,
+			events: []Event{
+				AnswerJudged{PlayerId: 3, QuestionId: "1_1_1", Correct: false, ScoreChange: -100, Unfreeze: true},
+				AnswerRevealed{QuestionId: "1_1_1"},
+			},
+		},
+		{
+			name:     "pass while answering when everyone has tried",
+			row:      "wait-answer pass -> show-answer [all tried]",
+			snapshot: with(waitState, func(s *Snapshot) { s.Clarifying = true }),
+			run:      func(g *Game) ([]Event, error) { return g.Pass(2), nil },
+			phase:    PhaseShowAnswer,
+			events:   []Event{AnswerRevealed{QuestionId: "1_1_1"}},
+			check: func(t *testing.T, g *Game) {
+				if g.Clarifying() {
+					t.Error("clarification is not over after leaving wait-answer")
+				}
+			},
+		},
+		{
+			name:     "pass while answering",
+			row:      "wait-answer pass -> wait-answer",
+			snapshot: &waitState,
+			run:      func(g *Game) ([]Event, error) { return g.Pass(1), nil },
+			phase:    PhaseWaitAnswer,
+		},
+		{
+			name:     "next turn in the same round",
+			row:      "show-answer next-turn -> select-question [round goes on]",
+			snapshot: &showState,
+			run:      func(g *Game) ([]Event, error) { return g.NextTurn(), nil },
+			phase:    PhaseSelectQuestion,
+			events:   []Event{TurnStarted{Round: 1, PlayerId: 1}},
+		},
+		{
+			name: "round change",
+			row:  "show-answer next-turn -> select-question [next round]",
+			snapshot: with(showState, func(s *Snapshot) {
+				s.Current = 2
+				s.Answered = map[string]bool{"1_1_1": true, "1_1_2": true}
+			}),
+			run:    func(g *Game) ([]Event, error) { return g.NextTurn(), nil },
+			phase:  PhaseSelectQuestion,
+			events: []Event{RoundStarted{Round: 2}, TurnStarted{Round: 2, PlayerId: 2}},
+			check: func(t *testing.T, g *Game) {
+				if open := g.OpenQuestions(); !reflect.DeepEqual(open, []string{"2_1_1"}) {
+					t.Errorf("open questions = %v", open)
+				}
+			},
+		},
+		{
+			name: "game over",
+			row:  "show-answer next-turn -> game-over",
+			snapshot: with(showState, func(s *Snapshot) {
+				s.Round = 2
+				s.Question = "2_1_1"
+				s.Answered = map[string]bool{"1_1_1": true, "1_1_2": true, "2_1_1": true}
+			}),
+			run:    func(g *Game) ([]Event, error) { return g.NextTurn(), nil },
+			phase:  PhaseGameOver,
+			events: []Event{GameOver{}},
+		},
+	}
+
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		covered[tt.row] = true
+		t.Run(tt.name, func(t *testing.T) {
+			players := tt.players
+			if players == nil {
+				players = testPlayers()
+			}
+			g := newTestGame(t, players, tt.snapshot)
+
+			events, err := tt.run(g)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if g.Phase() != tt.phase {
+				t.Errorf("phase = %s, want %s", g.Phase(), tt.phase)
+			}
+			if !sameEvents(events, tt.events) {
+				t.Errorf("events = %#v, want %#v", events, tt.events)
+			}
+			if tt.check != nil {
+				tt.check(t, g)
+			}
+		})
+	}
+
+	for _, tr := range transitions {
+		if !covered[rowName(tr)] {
+			t.Errorf("transition %q is not tested", rowName(tr))
+		}
+	}
+}
+
+var triggers = []Trigger{
+	TriggerLoad,
+	TriggerJoin,
+	TriggerLeave,
+	TriggerStart,
+	TriggerAcknowledgeStart,
+	TriggerStartTimeout,
+	TriggerSelectQuestion,
+	TriggerQuestionShown,
+	TriggerBuzz,
+	TriggerResolveBuzz,
+	TriggerClarify,
+	TriggerAnswerCorrect,
+	TriggerAnswerWrong,
+	TriggerPass,
+	TriggerNextTurn,
+}
+
+// commands вызывают команду по ее триггеру с допустимыми аргументами, чтобы
+// отказать она могла только из-за фазы. Уточнение приходит через
+// SubmitAnswer и проверяется им как answer-wrong. Команды таймеров ошибок
+// не возвращают.
+var commands = map[Trigger]func(g *Game) ([]Event, error){
+	TriggerLoad:             func(g *Game) ([]Event, error) { return nil, g.Load(testBoard()) },
+	TriggerJoin:             func(g *Game) ([]Event, error) { return nil, g.Join(&Player{ID: 9, Role: RolePlayer}) },
+	TriggerLeave:            func(g *Game) ([]Event, error) { return nil, g.Leave(2) },
+	TriggerStart:            (*Game).Start,
+	TriggerAcknowledgeStart: func(g *Game) ([]Event, error) { return g.AcknowledgeStart(1) },
+	TriggerSelectQuestion:   func(g *Game) ([]Event, error) { return g.SelectQuestion(1, "1_1_2") },
+	TriggerBuzz:             func(g *Game) ([]Event, error) { return g.Buzz(2) },
+	TriggerAnswerCorrect:    func(g *Game) ([]Event, error) { return g.SubmitAnswer(1, "1_1_1", Verdict{Correct: true}) },
+	TriggerAnswerWrong:      func(g *Game) ([]Event, error) { return g.SubmitAnswer(1, "1_1_1", Verdict{}) },
+}
+
+var timerCommands = map[Trigger]func(g *Game) []Event{
+	TriggerStartTimeout:  (*Game).StartTimeout,
+	TriggerQuestionShown: func(g *Game) []Event { return g.QuestionShown(1) },
+	TriggerResolveBuzz:   (*Game).ResolveBuzz,
+	TriggerPass:          func(g *Game) []Event { return g.Pass(2) },
+	TriggerNextTurn:      (*Game).NextTurn,
+}
+
+// TestIllegalTriggers проверяет каждую пару фазы и триггера, которой нет
+// в таблице переходов: команда отказывает с *TransitionError, а команда
+// таймера ничего не меняет.
+func TestIllegalTriggers(t *testing.T) {
+	legal := make(map[Phase]map[Trigger]bool)
+	for _, tr := range transitions {
+		if legal[tr.from] == nil {
+			legal[tr.from] = make(map[Trigger]bool)
+		}
+		legal[tr.from][tr.trigger] = true
+	}
+
+	for _, phase := range phases {
+		for _, trigger := range triggers {
+			if legal[phase][trigger] {
+				continue
+			}
+			t.Run(string(phase)+"/"+string(trigger), func(t *testing.T) {
+				g := newTestGame(t, testPlayers(), phaseState(phase))
+				before := g.Snapshot()
+
+				var te *TransitionError
+				if err := g.Can(trigger); !errors.As(err, &te) || te.Phase != phase || te.Trigger != trigger {
+					t.Errorf("Can = %v, want TransitionError", err)
+				}
+				if _, err := g.fire(trigger); !errors.As(err, &te) || !errors.Is(err, ErrWrongPhase) {
+					t.Errorf("fire = %v, want TransitionError", err)
+				}
+
+				if command, ok := commands[trigger]; ok {
+					events, err := command(g)
+					if !errors.As(err, &te) || te.Phase != phase || te.Trigger != trigger {
+						t.Errorf("command error = %v, want TransitionError for %s in %s", err, trigger, phase)
+					}
+					if len(events) != 0 {
+						t.Errorf("command events = %#v", events)
+					}
+				}
+				if command, ok := timerCommands[trigger]; ok {
+					if events := command(g); len(events) != 0 {
+						t.Errorf("timer command events = %#v", events)
+					}
+				}
+
+				if after := g.Snapshot(); !reflect.DeepEqual(after, before) {
+					t.Errorf("state changed:\n%+v\nwant\n%+v", after, before)
+				}
+			})
+		}
+	}
+}
+
+func TestGuardErrors(t *testing.T) {
+	t.Run("start without players", func(t *testing.T) {
+		g := newTestGame(t, []Player{{ID: 4, Role: RoleHost}, {ID: 5, Role: RoleSpectator}}, nil)
+		_, err := g.Start()
+
+		var ge *GuardError
+		if !errors.As(err, &ge) || ge.Guard != hasContestants.name || !errors.Is(err, ErrNoPlayers) {
+			t.Errorf("Start = %v, want GuardError with ErrNoPlayers", err)
+		}
+		if g.Phase() != PhaseJoining {
+			t.Errorf("phase = %s", g.Phase())
+		}
+	})
+
+	t.Run("resolve without buzzes", func(t *testing.T) {
+		g := newTestGame(t, testPlayers(), &questionState)
+		_, err := g.fire(TriggerResolveBuzz)
+
+		var ge *GuardError
+		if !errors.As(err, &ge) || ge.Guard != hasBuzzes.name || !errors.Is(err, ErrGuard) {
+			t.Errorf("fire = %v, want GuardError", err)
+		}
+		if events := g.ResolveBuzz(); len(events) != 0 || g.Phase() != PhaseQuestion {
+			t.Errorf("ResolveBuzz = %#v in phase %s", events, g.Phase())
+		}
+	})
+}
+
+func TestCommandErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot *Snapshot
+		run      func(g *Game) ([]Event, error)
+		want     error
+	}{
+		{"join with a taken id", nil, func(g *Game) ([]Event, error) { return nil, g.Join(&Player{ID: 1}) }, ErrPlayerExists},
+		{"NPC acknowledges start", &startAckState, func(g *Game) ([]Event, error) { return g.AcknowledgeStart(3) }, ErrNPC},
+		{"unknown player acknowledges start", &startAckState, func(g *Game) ([]Event, error) { return g.AcknowledgeStart(9) }, ErrUnknownPlayer},
+		{"select out of turn", &selectState, func(g *Game) ([]Event, error) { return g.SelectQuestion(2, "1_1_1") }, ErrNotYourTurn},
+		{"select unknown question", &selectState, func(g *Game) ([]Event, error) { return g.SelectQuestion(1, "2_1_1") }, ErrUnknownQuestion},
+		{"select answered question", with(selectState, func(s *Snapshot) { s.Answered = map[string]bool{"1_1_1": true} }),
+			func(g *Game) ([]Event, error) { return g.SelectQuestion(1, "1_1_1") }, ErrAnswered},
+		{"host buzzes", &questionState, func(g *Game) ([]Event, error) { return g.Buzz(4) }, ErrUnknownPlayer},
+		{"buzz twice", with(questionState, func(s *Snapshot) { s.Tried = []int{1} }), func(g *Game) ([]Event, error) { return g.Buzz(1) }, ErrAlreadyTried},
+		{"answer out of turn", &waitState, func(g *Game) ([]Event, error) { return g.SubmitAnswer(2, "1_1_1", Verdict{Correct: true}) }, ErrNotYourTurn},
+		{"answer another question", &waitState, func(g *Game) ([]Event, error) { return g.SubmitAnswer(1, "1_1_2", Verdict{Correct: true}) }, ErrUnknownQuestion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, testPlayers(), tt.snapshot)
+			before := g.Snapshot()
+
+			events, err := tt.run(g)
+			if !errors.Is(err, tt.want) || len(events) != 0 {
+				t.Errorf("got %#v, %v, want %v", events, err, tt.want)
+			}
+			if after := g.Snapshot(); !reflect.DeepEqual(after, before) {
+				t.Errorf("state changed:\n%+v\nwant\n%+v", after, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Snapshot - состояние игры без игроков, его можно сохранить в JSON и вернуть
+// через Restore. Игроков с очками вызывающий хранит сам и перед Restore
+// добавляет через Join: на них у него свои ссылки.
+type Snapshot struct {
+	Phase      Phase           `json:"phase"`
+	Board      Board           `json:"board"`
+	Current    int             `json:"current"`
+	Round      int             `json:"round"`
+	Answered   map[string]bool `json:"answered"`
+	Question   string          `json:"question"`
+	Acks       map[int]bool    `json:"acks"`
+	Shown      map[int]bool    `json:"shown"`
+	Opened     bool            `json:"opened"`
+	Buzzes     []int           `json:"buzzes"`
+	Tried      []int           `json:"tried"`
+	Answering  int             `json:"answering"`
+	Clarifying bool            `json:"clarifying"`
+}
+
+func (g *Game) Snapshot() Snapshot {
+	return Snapshot{
+		Phase:      g.phase,
+		Board:      g.board,
+		Current:    g.current,
+		Round:      g.round,
+		Answered:   copyMap(g.answered),
+		Question:   g.question,
+		Acks:       copyMap(g.acks),
+		Shown:      copyMap(g.shown),
+		Opened:     g.opened,
+		Buzzes:     append([]int(nil), g.buzzes...),
+		Tried:      append([]int(nil), g.tried...),
+		Answering:  g.answering,
+		Clarifying: g.clarifying,
+	}
+}
+
+// Restore переводит новую игру в состояние из снимка, минуя таблицу
+// переходов. Действия входа в фазу не выполняются, таймеры фазы
+// вызывающий заводит сам.
+func (g *Game) Restore(s Snapshot) error {
+	if g.phase != PhaseJoining {
+		return fmt.Errorf("%w: restore in phase %s", ErrWrongPhase, g.phase)
+	}
+	if !slices.Contains(phases, s.Phase) {
+		return fmt.Errorf("unknown phase %q", s.Phase)
+	}
+
+	g.phase = s.Phase
+	g.board = s.Board
+	g.current = s.Current
+	g.round = s.Round
+	g.answered = copyMap(s.Answered)
+	g.question = s.Question
+	g.acks = copyMap(s.Acks)
+	g.shown = copyMap(s.Shown)
+	g.opened = s.Opened
+	g.buzzes = append([]int(nil), s.Buzzes...)
+	g.tried = append([]int(nil), s.Tried...)
+	g.answering = s.Answering
+	g.clarifying = s.Clarifying
+	return nil
+}
+
+func copyMap[K comparable](m map[K]bool) map[K]bool {
+	c := make(map[K]bool, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
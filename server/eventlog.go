@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sigo-server/engine"
+)
+
+// Журнал игры лежит в rooms/<код>/events.jsonl: по строке JSON на команду
+// клиента, событие движка и сообщение WebSocket. Новый журнал начинается с
+// загрузкой пакета, после перезапуска сервера запись продолжается в тот же файл.
+
+const eventLogFile = "events.jsonl"
+
+// Виды записей журнала
+const (
+	logCommand = "command" // POST-запрос к комнате
+	logEvent   = "event"   // событие движка
+	logMessage = "message" // сообщение, разосланное клиентам
+)
+
+type logEntry struct {
+	Time int64           `json:"t"` // миллисекунды Unix
+	Kind string          `json:"kind"`
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// EventLog пишут игровой цикл, горутины ведущего и обработчики,
+// поэтому запись под mu.
+type EventLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func openEventLog(path string) *EventLog {
+	l := &EventLog{path: path}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("Warning: Failed to open event log: %v", err)
+	}
+	l.file = file
+	return l
+}
+
+// record дописывает запись. data либо уже готовый JSON ([]byte), либо
+// значение для json.Marshal.
+func (l *EventLog) record(kind string, name string, data interface{}) {
+	l.write(time.Now(), kind, name, data)
+}
+
+func (l *EventLog) write(t time.Time, kind string, name string, data interface{}) {
+	entry := logEntry{Time: t.UnixMilli(), Kind: kind, Name: name}
+	switch d := data.(type) {
+	case nil:
+	case []byte:
+		entry.Data = d
+	default:
+		raw, err := json.Marshal(d)
+		if err != nil {
+			log.Printf("event log: %v", err)
+			return
+		}
+		entry.Data = raw
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("event log: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		log.Printf("event log: %v", err)
+	}
+}
+
+// recordEvent пишет событие движка под именем его типа.
+func (l *EventLog) recordEvent(event engine.Event) {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", event), "engine.")
+	l.record(logEvent, name, event)
+}
+
+// restart начинает журнал заново для новой игры.
+func (l *EventLog) restart() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return
+	}
+	if err := l.file.Truncate(0); err != nil {
+		log.Printf("event log: %v", err)
+	}
+}
+
+func (l *EventLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// statusRecorder запоминает код ответа обработчика для журнала.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// recordCommand пишет POST-запрос к комнате: команду, параметры формы и код
+// ответа. Время записи - начало запроса, а в файл она попадает после
+// событий, которые команда вызвала.
+func (l *EventLog) recordCommand(start time.Time, r *http.Request, status int) {
+	command := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	l.write(start, logCommand, command, map[string]interface{}{
+		"form":   r.Form,
+		"status": status,
+	})
+}
+
+// handleEventLog отдает журнал комнаты целиком.
+func (room *Room) handleEventLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	http.ServeFile(w, r, room.events.path)
+}
+
+// handleReplay проигрывает по WebSocket сообщения из журнала комнаты с теми же
+// паузами, что были в игре. speed ускоряет воспроизведение (speed=4 -
+// в четыре раза быстрее). Экран подключается к /replay вместо /ws.
+func (room *Room) handleReplay(w http.ResponseWriter, r *http.Request) {
+	speed := 1.0
+	if s := r.FormValue("speed"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 {
+			http.Error(w, "Invalid speed", http.StatusBadRequest)
+			return
+		}
+		speed = v
+	}
+
+	file, err := os.Open(room.events.path)
+	if err != nil {
+		http.Error(w, "No event log", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	conn, err := room.gameState.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Клиент может закрыть повтор в любой момент
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var last int64
+	for scanner.Scan() {
+		var entry logEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Kind != logMessage {
+			continue
+		}
+
+		if last != 0 && entry.Time > last {
+			pause := time.Duration(float64(time.Duration(entry.Time-last)*time.Millisecond) / speed)
+			select {
+			case <-time.After(pause):
+			case <-closed:
+				return
+			}
+		}
+		last = entry.Time
+
+		if err := conn.WriteMessage(websocket.TextMessage, entry.Data); err != nil {
+			return
+		}
+	}
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "replay finished"))
+}
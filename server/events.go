@@ -0,0 +1,189 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/goldenpineappleofthesun/siclo"
+
+	"sigo-server/engine"
+)
+
+// Сколько ждем подтверждений старта от игроков
+const startAcknowledgeWindow = 30 * time.Second
+
+// Сколько после первого нажатия "ответить" ждем остальных нажавших
+const buzzWindow = 3 * time.Second
+
+// Сколько показываем правильный ответ перед следующим ходом
+const showAnswerPause = 5 * time.Second
+
+// Сколько NPC выбирает вопрос
+const npcSelectDelay = 2 * time.Second
+
+// apply рассылает события движка клиентам, заводит таймеры и просит ведущего
+// прокомментировать ход. Вызывается из игрового цикла.
+func (room *Room) apply(events []engine.Event) {
+	for _, event := range events {
+		room.events.recordEvent(event)
+
+		switch e := event.(type) {
+		case engine.Started:
+			log.Printf("[%s] game started, first player %d", room.code, e.FirstPlayer)
+			room.gameState.broadcastMessage("start", map[string]interface{}{})
+
+			playerNames := make([]string, 0, len(e.Players))
+			for _, id := range e.Players {
+				playerNames = append(playerNames, room.gameState.players[id].Name)
+			}
+			room.hostTalk(func(prompts *siclo.Prompts, host siclo.Character) (*siclo.HostLine, error) {
+				return siclo.HostGameStart(prompts, host, playerNames)
+			})
+
+			if room.gameStateInternal.startAcknowledgeTimeout != nil {
+				room.gameStateInternal.startAcknowledgeTimeout.Stop()
+			}
+			room.gameStateInternal.startAcknowledgeTimeout = room.later(startAcknowledgeWindow, func() []engine.Event {
+				return room.game.StartTimeout()
+			})
+
+		case engine.TurnStarted:
+			table, _, _ := room.getQuestionsTable(e.Round-1, true)
+			room.gameState.broadcastMessage("questionstable", map[string]interface{}{
+				"table": table,
+			})
+
+			// ход NPC
+			if p := room.gameState.players[e.PlayerId]; p != nil && p.Role == engine.RoleNPC {
+				room.npcTurn(e.PlayerId)
+			}
+
+		case engine.RoundStarted:
+			log.Printf("[%s] round %d", room.code, e.Round)
+			round := e.Round
+			themes := room.getRoundThemes(round - 1)
+			room.hostTalk(func(prompts *siclo.Prompts, host siclo.Character) (*siclo.HostLine, error) {
+				return siclo.HostRoundChange(prompts, host, round, themes)
+			})
+
+		case engine.GameOver:
+			scores := make([]siclo.PlayerScore, 0, len(room.gameState.players))
+			for _, player := range room.gameState.players {
+				if player.Contestant() {
+					scores = append(scores, siclo.PlayerScore{Name: player.Name, Score: player.Score})
+				}
+			}
+			room.hostTalk(func(prompts *siclo.Prompts, host siclo.Character) (*siclo.HostLine, error) {
+				return siclo.HostGameEnd(prompts, host, scores)
+			})
+			log.Printf("[%s] game over, model usage: %s", room.code, room.gameUsage.summary())
+
+		case engine.QuestionSelected:
+			log.Printf("[%s] select question %s", room.code, e.QuestionId)
+			room.gameStateInternal.npcAnswers = make(map[int]string)
+			room.gameState.broadcastMessage("questionselected", map[string]interface{}{
+				"id":        e.QuestionId,
+				"isspecial": false,
+			})
+
+			playerName := room.gameState.players[e.PlayerId].Name
+			themeName := room.getQuestionTheme(e.QuestionId)
+			price := e.Price
+			room.hostTalk(func(prompts *siclo.Prompts, host siclo.Character) (*siclo.HostLine, error) {
+				return siclo.HostQuestionSelected(prompts, host, playerName, themeName, price)
+			})
+
+		case engine.BuzzerOpened:
+			// Время когда всем станет доступна кнопка
+			canAnswerTime := time.Now().UTC().Add(500 * time.Millisecond)
+			room.gameStateInternal.canAnswerTimestamp = canAnswerTime.Unix() * 1000
+
+			room.gameState.broadcastMessage("cananswer", map[string]interface{}{
+				"timestamp": room.gameStateInternal.canAnswerTimestamp,
+			})
+			room.processNPCAnswers()
+
+		case engine.Buzzed:
+			room.gameState.broadcastMessage("stoptimer", map[string]interface{}{})
+			if e.First {
+				room.later(buzzWindow, func() []engine.Event {
+					return room.game.ResolveBuzz()
+				})
+			}
+
+		case engine.AnswerTurn:
+			log.Printf("[%s] player %d answers", room.code, e.PlayerId)
+			room.gameState.broadcastMessage("waitanswer", map[string]interface{}{
+				"playerId": e.PlayerId,
+			})
+			if p := room.gameState.players[e.PlayerId]; p != nil && p.Role == engine.RoleNPC {
+				room.answerNPC(e.PlayerId)
+			}
+
+		case engine.ClarifyRequested:
+			room.requestClarification(room.gameStateInternal.clarifyVerdict)
+
+		case engine.AnswerJudged:
+			room.gameState.broadcastMessage("validated", map[string]interface{}{
+				"idQuest":       e.QuestionId,
+				"playerId":      e.PlayerId,
+				"result":        e.Correct,
+				"unfreeze":      e.Unfreeze,
+				"appealSeconds": int(appealWindow.Seconds()),
+			})
+
+		case engine.NobodyAnswered:
+			question := room.getQuestion(e.QuestionId)
+			answer := room.getAnswer(e.QuestionId)
+			room.hostTalk(func(prompts *siclo.Prompts, host siclo.Character) (*siclo.HostLine, error) {
+				return siclo.HostNobodyAnswered(prompts, host, question, answer)
+			})
+
+		case engine.AnswerRevealed:
+			room.gameState.broadcastMessage("showanswer", map[string]interface{}{
+				"idQuest": e.QuestionId,
+			})
+			room.gameStateInternal.showAnswerTimeout = room.later(showAnswerPause, func() []engine.Event {
+				return room.game.NextTurn()
+			})
+		}
+	}
+}
+
+// later выполняет команду движка в игровом цикле после паузы.
+// Команда берет room.game в момент срабатывания, поэтому после сброса
+// игры старый таймер ничего не сломает. Срок попадает в снимок комнаты,
+// чтобы после перезапуска таймер дождался своего времени.
+func (room *Room) later(d time.Duration, command func() []engine.Event) *time.Timer {
+	room.gameStateInternal.deadline = time.Now().Add(d)
+	return room.after(d, func() {
+		room.apply(command())
+	})
+}
+
+// submit передает вердикт движку и запоминает его, чтобы игрок мог
+// оспорить решение через /appeal.
+func (room *Room) submit(verdict *Verdict) {
+	events, err := room.game.SubmitAnswer(verdict.PlayerId, verdict.IdQuest, engine.Verdict{
+		Correct: verdict.Result.Result,
+		Clarify: verdict.Result.Clarify,
+	})
+	if err != nil {
+		log.Printf("[%s] verdict for player %d dropped: %v", room.code, verdict.PlayerId, err)
+		return
+	}
+
+	for _, event := range events {
+		switch e := event.(type) {
+		case engine.ClarifyRequested:
+			room.gameStateInternal.clarifyVerdict = verdict
+		case engine.AnswerJudged:
+			if e.PlayerId == verdict.PlayerId {
+				verdict.ScoreChange = e.ScoreChange
+				verdict.Time = time.Now()
+				room.gameStateInternal.verdicts = append(room.gameStateInternal.verdicts, verdict)
+			}
+		}
+	}
+	room.apply(events)
+}
@@ -6,12 +6,12 @@ require (
 	github.com/goldenpineappleofthesun/siclo v0.0.0
 	github.com/goldenpineappleofthesun/siziph v0.0.0
 	github.com/gorilla/websocket v1.5.1
+	github.com/joho/godotenv v1.5.1
 	github.com/tidwall/gjson v1.18.0
 )
 
 require (
 	github.com/anthropics/anthropic-sdk-go v1.17.0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
 	github.com/tidwall/match v1.2.0 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
 	github.com/tidwall/sjson v1.2.5 // indirect
@@ -0,0 +1,49 @@
+package main
+
+import "time"
+
+// Игра комнаты живет в одной горутине loop. HTTP-обработчики, таймеры и
+// горутины с запросами к модели не трогают состояние сами, а передают
+// функции в цикл: do - с ожиданием результата, post - без него.
+// Из самого цикла do и post вызывать нельзя, они будут ждать сами себя.
+
+func (room *Room) loop() {
+	for {
+		select {
+		case <-room.gameState.done:
+			return
+		case command := <-room.commands:
+			command()
+			room.persist()
+		}
+	}
+}
+
+// do выполняет f в игровом цикле и ждет, пока она закончится.
+// Если комнату уже закрыли, f не выполняется.
+func (room *Room) do(f func()) {
+	finished := make(chan struct{})
+	select {
+	case room.commands <- func() {
+		defer close(finished)
+		f()
+	}:
+		<-finished
+	case <-room.gameState.done:
+	}
+}
+
+// post ставит f в очередь игрового цикла.
+func (room *Room) post(f func()) {
+	select {
+	case room.commands <- f:
+	case <-room.gameState.done:
+	}
+}
+
+// after выполняет f в игровом цикле через d.
+func (room *Room) after(d time.Duration, f func()) *time.Timer {
+	return time.AfterFunc(d, func() {
+		room.post(f)
+	})
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"archive/zip"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -13,6 +15,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"html/template"
 
@@ -22,39 +25,27 @@ import (
 
 	"github.com/goldenpineappleofthesun/siziph"
 	"github.com/goldenpineappleofthesun/siclo"
-)
 
-const (
-	StateJoining          = "joining"
-	StateStartAck         = "wait-start-ack"
-	StateSelectQuestion   = "select-question"
-	//StateQuestionAck      = "wait-question-ack"
-	StateQuestion         = "question"
-	StateWaitAnswer       = "wait-answer"
-	StateShowAnswer       = "show-answer"
-	StateUnknown          = "unknown"
+	"sigo-server/engine"
 )
 
+// GameState принадлежит игровому циклу комнаты (room.do), кроме clients:
+// их делят рассылка и обработчики WebSocket под mu.
 type GameState struct {
 	mu                sync.RWMutex
-	state             string
 	packageJson       map[string]interface{}
+	prompts           *siclo.Prompts // шаблоны промптов пакета, см. selectPrompts
 	players           map[int]*Player
-	nextNPCId         int
-	currentPlayerId   int
-	roundNum          int
-	gameStartTime     time.Time
-	answeredQuestions map[string]bool
-	clients           map[*websocket.Conn]bool
-	broadcast         chan []byte
+	nextId            int // номер для следующего вошедшего, см. addPlayer
+	clients           map[*client]bool
+	done              chan struct{} // закрывается, когда комнату удаляют
 	upgrader          websocket.Upgrader
+	events            *EventLog // журнал игры комнаты
 }
 
+// Player - игрок движка и то, что о нем знает только сервер. Очки ведет движок.
 type Player struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Score       int    `json:"score"`
-	IsNPC       bool   `json:"-"`
+	engine.Player
 	NPCCharacter *NPCCharacter `json:"-"` // Ссылка на персонажа NPC
 }
 
@@ -65,6 +56,14 @@ type NPCCharacter struct {
 	PlayerPrompt string `json:"player_prompt"`
 }
 
+func (c *NPCCharacter) toSiclo() siclo.Character {
+	return siclo.Character{
+		Name:         c.Name,
+		HostPrompt:   c.HostPrompt,
+		PlayerPrompt: c.PlayerPrompt,
+	}
+}
+
 type Question struct {
 	ID      int    `json:"id"`
 	Price   int    `json:"price"`
@@ -79,82 +78,129 @@ type Theme struct {
 	Questions []Question `json:"questions"`
 }
 
-var gameState *GameState
 var npcCharacters []NPCCharacter
 var npcCharactersMap map[string]*NPCCharacter // Для быстрого поиска по имени
+var hostTalkStreamId int64 // Номер потоковой реплики ведущего для hosttalkdelta
 /*var acknowledgeWaitStarted bool*/
 
 func init() {
-	gameState = &GameState{
-		state:             StateJoining,
-		players:           make(map[int]*Player),
-		nextNPCId:         100,
-		answeredQuestions: make(map[string]bool),
-		clients:           make(map[*websocket.Conn]bool),
-		broadcast:         make(chan []byte, 256),
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true
-			},
-		},
-	}
 	npcCharactersMap = make(map[string]*NPCCharacter)
 }
 
 func main() {
 	_ = godotenv.Load("../.env")
-	
-	// Очищаем папку players при старте сервера
-	os.RemoveAll("package")
-	os.RemoveAll("players")
+
+	// Параметры модели: переменные окружения SICLO_*, флаги их перекрывают
+	sicloConfig, err := siclo.ConfigFromEnv(siclo.DefaultConfig())
+	if err != nil {
+		log.Fatalf("Invalid siclo config: %v", err)
+	}
+	sicloConfig.RegisterFlags(flag.CommandLine)
+
+	budget := 0.0
+	if v := os.Getenv("SICLO_GAME_BUDGET"); v != "" {
+		if budget, err = strconv.ParseFloat(v, 64); err != nil {
+			log.Fatalf("Invalid SICLO_GAME_BUDGET: %v", err)
+		}
+	}
+	flag.Float64Var(&gameBudget, "game-budget", budget, "Max model spend per game in USD, then answers are validated locally (0 - no limit)")
+
+	flag.Parse()
+	if sicloConfig, err = sicloConfig.WithDefaults(); err != nil {
+		log.Fatalf("Invalid siclo config: %v", err)
+	}
+	siclo.Configure(sicloConfig)
+	log.Printf("Model %s via %s, max tokens %d, game budget $%.2f", sicloConfig.Model, sicloConfig.Provider, sicloConfig.MaxTokens, gameBudget)
 	
 	// Создаем необходимые папки
-	os.MkdirAll("package", 0755)
-	os.MkdirAll("players", 0755)
+	os.MkdirAll("rooms", 0755)
 	os.MkdirAll("npc_characters", 0755)
 
+	// Ключ подписи токенов игроков
+	if err := loadSessionKey(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
 	// Загружаем NPC персонажей при старте
 	if err := loadNPCCharacters(); err != nil {
 		log.Printf("Warning: Failed to load NPC characters: %v", err)
 	}
 
-	// Запускаем broadcaster для WebSocket
-	go gameState.broadcaster()
-
-	http.Handle("/index.html",       render("index"))
-	http.Handle("/join.html",        render("join"))
-	http.Handle("/joinhost.html",    render("joinhost"))
-	http.Handle("/joinnpc.html",     render("joinnpc"))
-	http.Handle("/client/",          http.StripPrefix("/client/",http.FileServer(http.Dir("/app/client")),),)
-	http.Handle("/upload",           withCORS(http.HandlerFunc(handleUpload)))
-	http.Handle("/join",             withCORS(http.HandlerFunc(handleJoin)))
-	http.Handle("/joinnpc",          withCORS(http.HandlerFunc(handleJoinNPC)))
-	http.Handle("/joinshowman",      withCORS(http.HandlerFunc(handleJoinShowman)))
-	http.Handle("/npccharacters",    withCORS(http.HandlerFunc(handleNPCCharacters)))
-	http.Handle("/state",            withCORS(http.HandlerFunc(handleState)))
-	http.Handle("/scores",           withCORS(http.HandlerFunc(handleScores)))
-	http.Handle("/data",             withCORS(http.HandlerFunc(handleData)))
-	http.Handle("/media",            withCORS(http.HandlerFunc(handleMedia)))
-	http.Handle("/currentplayer",    withCORS(http.HandlerFunc(handleCurrentPlayer)))
-	http.Handle("/currentround",     withCORS(http.HandlerFunc(handleCurrentRound)))
-	http.Handle("/playerstate",      withCORS(http.HandlerFunc(handlePlayerState)))
-	http.Handle("/start",            withCORS(http.HandlerFunc(handleStart)))
-	http.Handle("/actualize",        withCORS(http.HandlerFunc(handleActualize)))
-	http.Handle("/startacknowledge", withCORS(http.HandlerFunc(handleStartAcknowledge)))
-	http.Handle("/selectquestion",   withCORS(http.HandlerFunc(handleSelectQuestion)))
-	http.Handle("/questionbeenshown",withCORS(http.HandlerFunc(handleQuestionBeenShown)))
-	http.Handle("/requestanswer",    withCORS(http.HandlerFunc(handleRequestAnswer)))
-	http.Handle("/answer",           withCORS(http.HandlerFunc(handleAnswer)))
-	http.Handle("/timerdone",        withCORS(http.HandlerFunc(handleTimerDone)))
-	http.Handle("/reset",            withCORS(http.HandlerFunc(handleReset)))
-	http.Handle("/ws",               withCORS(http.HandlerFunc(handleWebSocket)))
+	if prompts, err := selectPrompts(nil); err != nil {
+		log.Printf("Warning: Failed to load prompts: %v", err)
+	} else {
+		defaultPrompts = prompts
+	}
 
-	log.Println("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
-}
+	// Поднимаем комнаты с их играми из снимков, NPC и промпты уже загружены
+	rooms.restore()
+
+	// Кеш вердиктов переживает перезапуски, поэтому лежит вне package и players
+	if err := openVerdictCache(); err != nil {
+		log.Printf("Warning: Failed to open verdict cache: %v", err)
+	}
+
+	// Брошенные комнаты удаляем раз в час
+	go func() {
+		for range time.Tick(time.Hour) {
+			rooms.cleanup()
+		}
+	}()
 
-func render(page string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	log.Println("Server starting on :8080")
+	log.Fatal(http.ListenAndServe(":8080", newMux()))
+}
+
+// newMux собирает маршруты сервера.
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Ведущий открывает /joinhost.html и получает новую комнату,
+	// игроки входят по коду через /join.html?code=ABCD
+	mux.Handle("/joinhost.html",    http.HandlerFunc(handleNewRoom))
+	mux.Handle("/join.html",        handleEnterRoom("join.html"))
+	mux.Handle("/joinnpc.html",     handleEnterRoom("joinnpc.html"))
+	mux.Handle("/client/",          http.StripPrefix("/client/",http.FileServer(http.Dir("/app/client")),),)
+	mux.Handle("/rooms",            withCORS(http.HandlerFunc(handleRooms)))
+	mux.Handle("/npccharacters",    withCORS(http.HandlerFunc(handleNPCCharacters)))
+	mux.Handle("/statemachine",     withCORS(http.HandlerFunc(handleStateMachine)))
+
+	// Все, что относится к игре, живет внутри комнаты
+	mux.Handle("/rooms/{code}/index.html",       inRoom(render("index")))
+	mux.Handle("/rooms/{code}/join.html",        inRoom(render("join")))
+	mux.Handle("/rooms/{code}/joinhost.html",    inRoom(render("joinhost")))
+	mux.Handle("/rooms/{code}/joinnpc.html",     inRoom(render("joinnpc")))
+	mux.Handle("/rooms/{code}/upload",           withCORS(inRoom(asHost((*Room).handleUpload))))
+	mux.Handle("/rooms/{code}/join",             withCORS(inRoom((*Room).handleJoin)))
+	mux.Handle("/rooms/{code}/joinnpc",          withCORS(inRoom(asHost((*Room).handleJoinNPC))))
+	mux.Handle("/rooms/{code}/joinshowman",      withCORS(inRoom(asHost((*Room).handleJoinShowman))))
+	mux.Handle("/rooms/{code}/npccharacters",    withCORS(http.HandlerFunc(handleNPCCharacters)))
+	mux.Handle("/rooms/{code}/state",            withCORS(inRoom((*Room).handleState)))
+	mux.Handle("/rooms/{code}/scores",           withCORS(inRoom((*Room).handleScores)))
+	mux.Handle("/rooms/{code}/data",             withCORS(inRoom((*Room).handleData)))
+	mux.Handle("/rooms/{code}/media",            withCORS(inRoom((*Room).handleMedia)))
+	mux.Handle("/rooms/{code}/currentplayer",    withCORS(inRoom((*Room).handleCurrentPlayer)))
+	mux.Handle("/rooms/{code}/currentround",     withCORS(inRoom((*Room).handleCurrentRound)))
+	mux.Handle("/rooms/{code}/playerstate",      withCORS(inRoom((*Room).handlePlayerState)))
+	mux.Handle("/rooms/{code}/start",            withCORS(inRoom(asHost((*Room).handleStart))))
+	mux.Handle("/rooms/{code}/startacknowledge", withCORS(inRoom(asPlayer((*Room).handleStartAcknowledge))))
+	mux.Handle("/rooms/{code}/selectquestion",   withCORS(inRoom(asPlayer((*Room).handleSelectQuestion))))
+	mux.Handle("/rooms/{code}/questionbeenshown",withCORS(inRoom(asPlayer((*Room).handleQuestionBeenShown))))
+	mux.Handle("/rooms/{code}/requestanswer",    withCORS(inRoom(asPlayer((*Room).handleRequestAnswer))))
+	mux.Handle("/rooms/{code}/answer",           withCORS(inRoom(asPlayer((*Room).handleAnswer))))
+	mux.Handle("/rooms/{code}/timerdone",        withCORS(inRoom(asPlayer((*Room).handleTimerDone))))
+	mux.Handle("/rooms/{code}/appeal",           withCORS(inRoom(asPlayer((*Room).handleAppeal))))
+	mux.Handle("/rooms/{code}/reset",            withCORS(inRoom(asHost((*Room).handleReset))))
+	mux.Handle("/rooms/{code}/usage",            withCORS(inRoom((*Room).handleUsage)))
+	mux.Handle("/rooms/{code}/ws",               withCORS(inRoom(asPlayer((*Room).handleWebSocket))))
+	mux.Handle("/rooms/{code}/events",           withCORS(inRoom(asHost((*Room).handleEventLog))))
+	mux.Handle("/rooms/{code}/replay",           withCORS(inRoom(asHost((*Room).handleReplay))))
+
+	return mux
+}
+
+func render(page string) func(room *Room, w http.ResponseWriter, r *http.Request) {
+	return func(room *Room, w http.ResponseWriter, r *http.Request) {
 		tmpl, err := template.ParseFiles("/app/client/" + page + ".html")
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -168,6 +214,8 @@ func render(page string) http.HandlerFunc {
 		if data["host"] == "" {
 			data["host"] = "http://localhost:3000"
 		}
+		data["host"] += "/rooms/" + room.code
+		data["room"] = room.code
 
 		if err := tmpl.Execute(w, data); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -175,24 +223,6 @@ func render(page string) http.HandlerFunc {
 	}
 }
 
-func (gs *GameState) broadcaster() {
-	for {
-		select {
-		case message := <-gs.broadcast:
-			gs.mu.RLock()
-			for client := range gs.clients {
-				err := client.WriteMessage(websocket.TextMessage, message)
-				if err != nil {
-					log.Printf("WebSocket error: %v", err)
-					client.Close()
-					delete(gs.clients, client)
-				}
-			}
-			gs.mu.RUnlock()
-		}
-	}
-}
-
 func (gs *GameState) broadcastMessage(msgType string, data map[string]interface{}) {
 	message := map[string]interface{}{
 		"type": msgType,
@@ -201,54 +231,32 @@ func (gs *GameState) broadcastMessage(msgType string, data map[string]interface{
 		message[k] = v
 	}
 	jsonMsg, _ := json.Marshal(message)
-	gs.broadcast <- jsonMsg
-}
-
-func handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := gameState.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
-		return
-	}
-	defer conn.Close()
-
-	gameState.mu.Lock()
-	gameState.clients[conn] = true
-	gameState.mu.Unlock()
-
-	// Keep connection alive
-	for {
-		_, _, err := conn.ReadMessage()
-		if err != nil {
-			break
-		}
-	}
-
-	gameState.mu.Lock()
-	delete(gameState.clients, conn)
-	gameState.mu.Unlock()
+	gs.events.record(logMessage, msgType, jsonMsg)
+	gs.broadcast(jsonMsg)
 }
 
 // HTTP обработчики
 
-func handleUpload(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
+	// Очищаем состояние игры
+	room.do(func() {
+		room.gameStateInternal.stopTimers()
+		room.game = engine.NewGame(nil)
+		room.gameState.players = make(map[int]*Player)
+		room.gameState.packageJson = nil
+		room.gameState.prompts = defaultPrompts
+	})
+	room.gameUsage.reset()
+	room.events.restart()
 
 	// Очищаем папку package
-	os.RemoveAll("package")
-	os.MkdirAll("package", 0755)
-
-	// Очищаем состояние игры
-	gameState.players = make(map[int]*Player)
-	gameState.nextNPCId = 100
-	gameState.answeredQuestions = make(map[string]bool)
-	gameState.state = StateJoining
+	os.RemoveAll(room.path("package"))
+	os.MkdirAll(room.path("package"), 0755)
 
 	var siqBytes []byte
 	var err error
@@ -311,17 +319,17 @@ func handleUpload(w http.ResponseWriter, r *http.Request) {
 	tmpFile.Close()
 
 	// Извлекаем SIQ файл в папку package
-	if err := siziph.Extract(tmpFile.Name(), "package"); err != nil {
+	if err := siziph.Extract(tmpFile.Name(), room.path("package")); err != nil {
 		http.Error(w, fmt.Sprintf("Error extracting SIQ file: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Загружаем content.json из извлеченного пакета
-	contentJsonPath := filepath.Join("package", "content.json")
+	contentJsonPath := filepath.Join(room.path("package"), "content.json")
 	jsonBytes, err := os.ReadFile(contentJsonPath)
 	if err != nil {
 		// Если content.json не найден, пробуем загрузить content.xml и преобразовать
-		contentXmlPath := filepath.Join("package", "content.xml")
+		contentXmlPath := filepath.Join(room.path("package"), "content.xml")
 		if _, err := os.Stat(contentXmlPath); err == nil {
 			// siziph автоматически конвертирует XML в JSON, попробуем еще раз
 			jsonBytes, err = os.ReadFile(contentJsonPath)
@@ -341,57 +349,85 @@ func handleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gameState.packageJson = packageJson
+	prompts, err := selectPrompts(packageJson)
+	if err != nil {
+		log.Printf("Warning: Failed to load prompts for package: %v", err)
+		prompts = defaultPrompts
+	}
+
+	room.do(func() {
+		room.gameState.packageJson = packageJson
+		room.gameState.prompts = prompts
+		room.game.Load(room.packageBoard())
+	})
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Package uploaded successfully"))
 }
 
-func handleJoin(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleJoin(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
-
-	if gameState.state != StateJoining {
-		http.Error(w, "Game is not in joining state", http.StatusBadRequest)
+	// Форма нужна новому игроку, вернувшийся может прислать один токен
+	err := r.ParseMultipartForm(32 << 20)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, "Error parsing multipart form", http.StatusBadRequest)
 		return
 	}
 
-	err := r.ParseMultipartForm(32 << 20)
-	if err != nil {
-		http.Error(w, "Error parsing multipart form", http.StatusBadRequest)
+	// С токеном игрок возвращается на свое место с теми же очками: например,
+	// после перезагрузки телефона. Возвращаться можно в любой фазе.
+	if token := requestToken(r); token != "" {
+		// сам токен в журнал не пишем
+		r.Form.Del("token")
+		room.handleRejoin(w, token)
 		return
 	}
 
-	idStr := r.FormValue("id")
 	name := r.FormValue("name")
-	
-	if idStr == "" || name == "" {
-		http.Error(w, "Missing id or name", http.StatusBadRequest)
+	if name == "" {
+		http.Error(w, "Missing name", http.StatusBadRequest)
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
+	role := engine.Role(r.FormValue("role"))
+	switch role {
+	case "":
+		role = engine.RolePlayer
+	case engine.RolePlayer, engine.RoleSpectator:
+	default:
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	player := &Player{
+		Player: engine.Player{Name: name, Role: role},
+	}
+	var joinErr error
+	room.do(func() {
+		joinErr = room.addPlayer(player)
+	})
+	if joinErr != nil {
+		engineError(w, joinErr)
 		return
 	}
+	id := player.ID
+	log.Printf("[%s] %s %d joined: %s", room.code, role, id, name)
 
 	// Сохраняем фото
 	photo, photoHeader, err := r.FormFile("photo")
 	if err == nil {
 		defer photo.Close()
-		
+
 		ext := filepath.Ext(photoHeader.Filename)
 		if ext == "" {
 			ext = ".jpg"
 		}
-		
-		photoPath := filepath.Join("players", fmt.Sprintf("%d%s", id, ext))
+
+		photoPath := filepath.Join(room.path("players"), fmt.Sprintf("%d%s", id, ext))
 		dst, err := os.Create(photoPath)
 		if err == nil {
 			io.Copy(dst, photo)
@@ -399,18 +435,45 @@ func handleJoin(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	gameState.players[id] = &Player{
-		ID:    id,
-		Name:  name,
-		Score: 0,
-		IsNPC: false,
+	room.writeSession(w, id, role)
+}
+
+// handleRejoin возвращает игрока по токену: номер, роль и очки остаются прежними.
+func (room *Room) handleRejoin(w http.ResponseWriter, token string) {
+	id, ok := room.sessionPlayer(token)
+	if !ok {
+		http.Error(w, "Invalid session token", http.StatusUnauthorized)
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Player joined successfully"))
+	var role engine.Role
+	room.do(func() {
+		if player, exists := room.gameState.players[id]; exists {
+			role = player.Role
+		}
+	})
+	// Игрока могло не стать после сброса игры
+	if role == "" {
+		http.Error(w, "Player not found", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("[%s] %s %d rejoined", room.code, role, id)
+	room.writeSession(w, id, role)
+}
+
+// writeSession отвечает на вход номером, ролью и токеном, который игрок дальше
+// передает с каждой командой.
+func (room *Room) writeSession(w http.ResponseWriter, id int, role engine.Role) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":    id,
+		"role":  role,
+		"token": room.sessionToken(id),
+	})
 }
 
-func handleJoinNPC(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleJoinNPC(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -435,39 +498,32 @@ func handleJoinNPC(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
-
-	if gameState.state != StateJoining {
-		http.Error(w, "Game is not in joining state", http.StatusBadRequest)
-		return
-	}
-
-	id := gameState.nextNPCId
-	gameState.nextNPCId++
+	room.do(func() {
+		player := &Player{
+			Player:       engine.Player{Name: npcChar.Name, Role: engine.RoleNPC},
+			NPCCharacter: npcChar,
+		}
+		if err := room.addPlayer(player); err != nil {
+			engineError(w, err)
+			return
+		}
+		id := player.ID
 
-	// Копируем фото из папки npc_characters в папку players
-	sourcePhoto := filepath.Join("npc_characters", npcChar.Photo)
-	destPhoto := filepath.Join("players", fmt.Sprintf("%d%s", id, filepath.Ext(npcChar.Photo)))
-	
-	if err := copyFile(sourcePhoto, destPhoto); err != nil {
-		log.Printf("Warning: Failed to copy NPC photo: %v", err)
-		// Продолжаем даже если не удалось скопировать фото
-	}
+		// Копируем фото из папки npc_characters в папку players
+		sourcePhoto := filepath.Join("npc_characters", npcChar.Photo)
+		destPhoto := filepath.Join(room.path("players"), fmt.Sprintf("%d%s", id, filepath.Ext(npcChar.Photo)))
 
-	gameState.players[id] = &Player{
-		ID:           id,
-		Name:         npcChar.Name,
-		Score:        0,
-		IsNPC:        true,
-		NPCCharacter: npcChar,
-	}
+		if err := copyFile(sourcePhoto, destPhoto); err != nil {
+			log.Printf("Warning: Failed to copy NPC photo: %v", err)
+			// Продолжаем даже если не удалось скопировать фото
+		}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(fmt.Sprintf("NPC joined with id %d", id)))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf("NPC joined with id %d", id)))
+	})
 }
 
-func handleJoinShowman(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleJoinShowman(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -492,47 +548,45 @@ func handleJoinShowman(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
-
-	if gameState.state != StateJoining {
-		http.Error(w, "Game is not in joining state", http.StatusBadRequest)
-		return
-	}
+	room.do(func() {
+		if err := room.game.Can(engine.TriggerJoin); err != nil {
+			engineError(w, err)
+			return
+		}
 
-	// Всегда используем ID 1000 для ведущего
-	id := 1000
+		// Ведущий один: предыдущего убираем вместе с фото
+		if previous := room.host(); previous != nil {
+			extensions := []string{".jpg", ".jpeg", ".png", ".gif"}
+			for _, ext := range extensions {
+				photoPath := filepath.Join(room.path("players"), fmt.Sprintf("%d%s", previous.ID, ext))
+				os.Remove(photoPath)
+			}
+			delete(room.gameState.players, previous.ID)
+			room.game.Leave(previous.ID)
+		}
 
-	// Удаляем предыдущего игрока с ID 1000, если он существует
-	if _, exists := gameState.players[id]; exists {
-		// Удаляем фото предыдущего игрока, если оно есть
-		extensions := []string{".jpg", ".jpeg", ".png", ".gif"}
-		for _, ext := range extensions {
-			photoPath := filepath.Join("players", fmt.Sprintf("%d%s", id, ext))
-			os.Remove(photoPath)
+		player := &Player{
+			Player:       engine.Player{Name: npcChar.Name, Role: engine.RoleHost},
+			NPCCharacter: npcChar,
 		}
-		delete(gameState.players, id)
-	}
+		if err := room.addPlayer(player); err != nil {
+			engineError(w, err)
+			return
+		}
+		id := player.ID
 
-	// Копируем фото из папки npc_characters в папку players
-	sourcePhoto := filepath.Join("npc_characters", npcChar.Photo)
-	destPhoto := filepath.Join("players", fmt.Sprintf("%d%s", id, filepath.Ext(npcChar.Photo)))
-	
-	if err := copyFile(sourcePhoto, destPhoto); err != nil {
-		log.Printf("Warning: Failed to copy showman photo: %v", err)
-		// Продолжаем даже если не удалось скопировать фото
-	}
+		// Копируем фото из папки npc_characters в папку players
+		sourcePhoto := filepath.Join("npc_characters", npcChar.Photo)
+		destPhoto := filepath.Join(room.path("players"), fmt.Sprintf("%d%s", id, filepath.Ext(npcChar.Photo)))
 
-	gameState.players[id] = &Player{
-		ID:           id,
-		Name:         npcChar.Name,
-		Score:        0,
-		IsNPC:        true,
-		NPCCharacter: npcChar,
-	}
+		if err := copyFile(sourcePhoto, destPhoto); err != nil {
+			log.Printf("Warning: Failed to copy showman photo: %v", err)
+			// Продолжаем даже если не удалось скопировать фото
+		}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(fmt.Sprintf("Showman joined with id %d", id)))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf("Showman joined with id %d", id)))
+	})
 }
 
 func handleNPCCharacters(w http.ResponseWriter, r *http.Request) {
@@ -568,6 +622,54 @@ func loadNPCCharacters() error {
 	return nil
 }
 
+// Шаблоны промптов для комнат без пакета, выбираются при старте
+var defaultPrompts = siclo.DefaultPrompts()
+
+// selectPrompts выбирает шаблоны промптов по языку и названию пакета.
+// Язык берется из SICLO_LANGUAGE, а если он не задан - из самого пакета.
+// Шаблоны хранятся в комнате и передаются в каждый запрос к модели, так
+// что у каждой игры свой язык.
+func selectPrompts(packageJson map[string]interface{}) (*siclo.Prompts, error) {
+	language := os.Getenv("SICLO_LANGUAGE")
+	if language == "" {
+		language = getString(packageJson, "@language")
+	}
+	packageName := getString(packageJson, "@name")
+
+	prompts, err := siclo.LoadPrompts(os.Getenv("SICLO_PROMPTS_DIR"), language, packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Prompts: language %s, package %q", prompts.Language(), packageName)
+	return prompts, nil
+}
+
+func openVerdictCache() error {
+	path := os.Getenv("SICLO_CACHE_PATH")
+	if path == "" {
+		path = "siclo_cache.json"
+	}
+
+	ttl := siclo.DefaultCacheTTL
+	if v := os.Getenv("SICLO_CACHE_TTL"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid SICLO_CACHE_TTL: %w", err)
+		}
+		ttl = parsed
+	}
+
+	cache, err := siclo.OpenCache(path, ttl)
+	if err != nil {
+		return err
+	}
+	siclo.UseCache(cache)
+
+	log.Printf("Verdict cache %s: %d entries", path, cache.Stats().Entries)
+	return nil
+}
+
 func copyFile(src, dst string) error {
 	sourceFile, err := os.Open(src)
 	if err != nil {
@@ -585,57 +687,63 @@ func copyFile(src, dst string) error {
 	return err
 }
 
-func handleState(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleState(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.RLock()
-	state := gameState.state
-	gameState.mu.RUnlock()
+	var state string
+	room.do(func() {
+		state = string(room.game.Phase())
+	})
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(state))
 }
 
-func handleScores(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleScores(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.RLock()
-	scores := make([]map[string]interface{}, 0, len(gameState.players))
-	for _, player := range gameState.players {
-		scores = append(scores, map[string]interface{}{
-			"id":    player.ID,
-			"name":  player.Name,
-			"score": player.Score,
-		})
-	}
-	gameState.mu.RUnlock()
+	var scores []map[string]interface{}
+	room.do(func() {
+		scores = make([]map[string]interface{}, 0, len(room.gameState.players))
+		for _, player := range room.gameState.players {
+			scores = append(scores, map[string]interface{}{
+				"id":    player.ID,
+				"name":  player.Name,
+				"score": player.Score,
+				"role":  player.Role,
+			})
+		}
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(scores)
 }
 
-func handleData(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleData(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.RLock()
-	players := make([]map[string]interface{}, 0, len(gameState.players))
-	for _, player := range gameState.players {
-		players = append(players, map[string]interface{}{
-			"id":   player.ID,
-			"name": player.Name,
-		})
-	}
-	packageJson := gameState.packageJson
-	gameState.mu.RUnlock()
+	var players []map[string]interface{}
+	var packageJson map[string]interface{}
+	room.do(func() {
+		players = make([]map[string]interface{}, 0, len(room.gameState.players))
+		for _, player := range room.gameState.players {
+			players = append(players, map[string]interface{}{
+				"id":   player.ID,
+				"name": player.Name,
+				"role": player.Role,
+			})
+		}
+		packageJson = room.gameState.packageJson
+	})
 
 	response := map[string]interface{}{
 		"packageJson": packageJson,
@@ -646,7 +754,7 @@ func handleData(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func handleMedia(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleMedia(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -659,7 +767,7 @@ func handleMedia(w http.ResponseWriter, r *http.Request) {
 	defer zipWriter.Close()
 
 	// Добавляем медиа из package
-	filepath.Walk("package", func(path string, info os.FileInfo, err error) error {
+	filepath.Walk(room.path("package"), func(path string, info os.FileInfo, err error) error {
 		baseName := filepath.Base(path)
 		if err != nil || info.IsDir() || baseName == "package.json" || baseName == "content.json" || baseName == "content.xml" {
 			return nil
@@ -671,7 +779,7 @@ func handleMedia(w http.ResponseWriter, r *http.Request) {
 		}
 		defer file.Close()
 
-		relPath, _ := filepath.Rel("package", path)
+		relPath, _ := filepath.Rel(room.path("package"), path)
 		zipPath := filepath.Join("questions", relPath)
 		
 		zipFile, err := zipWriter.Create(zipPath)
@@ -684,7 +792,7 @@ func handleMedia(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// Добавляем фото игроков
-	filepath.Walk("players", func(path string, info os.FileInfo, err error) error {
+	filepath.Walk(room.path("players"), func(path string, info os.FileInfo, err error) error {
 		if err != nil || info.IsDir() {
 			return nil
 		}
@@ -708,35 +816,37 @@ func handleMedia(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func handleCurrentPlayer(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleCurrentPlayer(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.RLock()
-	currentPlayerId := gameState.currentPlayerId
-	gameState.mu.RUnlock()
+	var currentPlayerId int
+	room.do(func() {
+		currentPlayerId = room.game.CurrentPlayer()
+	})
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(strconv.Itoa(currentPlayerId)))
 }
 
-func handleCurrentRound(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleCurrentRound(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.RLock()
-	roundNum := gameState.roundNum
-	gameState.mu.RUnlock()
+	var roundNum int
+	room.do(func() {
+		roundNum = room.game.Round()
+	})
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(strconv.Itoa(roundNum)))
 }
 
-func handlePlayerState(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handlePlayerState(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -754,204 +864,174 @@ func handlePlayerState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gameState.mu.RLock()
-	player, exists := gameState.players[id]
-	gameState.mu.RUnlock()
+	var response map[string]interface{}
+	room.do(func() {
+		if player, exists := room.gameState.players[id]; exists {
+			response = map[string]interface{}{
+				"name":  player.Name,
+				"score": player.Score,
+				"role":  player.Role,
+			}
+		}
+	})
 
-	if !exists {
+	if response == nil {
 		http.Error(w, "Player not found", http.StatusNotFound)
 		return
 	}
 
-	response := map[string]interface{}{
-		"name":  player.Name,
-		"score": player.Score,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-type PlayerAnswerRequest struct {
-    playerId  int
-    timestamp int64
+// Verdict - решение по ответу игрока, которое он может оспорить через /appeal.
+type Verdict struct {
+	IdQuest     string
+	PlayerId    int
+	HostName    string
+	HostPrompt  string
+	Question    string
+	Expected    string
+	Answer      string
+	Result      siclo.ValidationResult
+	ScoreChange int
+	Time        time.Time
+	Appealed    bool
 }
 
+// Сколько времени после вердикта игрок может его оспорить
+const appealWindow = 30 * time.Second
+
+// Сколько времени у игрока, чтобы уточнить неполный ответ
+const clarifyWindow = 15 * time.Second
+
 // Дополнительные поля для GameState для управления игрой
 type GameStateInternal struct {
-	acknowledgesReceived     map[int]bool
-	questionShownReceived    map[int]bool
-	requestAnswerReceived    []PlayerAnswerRequest
-	playersAnswered          []int
-	startAcknowledgeReceived map[int]bool
-	selectedQuestionId       string
-	selectedQuestionTime     time.Time
+	npcAnswers               map[int]string
+	answerInProgress         bool
+	verdicts                 []*Verdict
+	clarifyVerdict           *Verdict
 	canAnswerTimestamp       int64
-	waitAnswerWinner         int
 	waitAnswerTimeout        *time.Timer
 	acknowledgeTimeout       *time.Timer
 	questionShownTimeout     *time.Timer
 	showAnswerTimeout        *time.Timer
 	startAcknowledgeTimeout  *time.Timer
+	deadline                 time.Time // когда сработает таймер текущей фазы
 }
 
-var gameStateInternal = &GameStateInternal{
-	acknowledgesReceived:     make(map[int]bool),
-	questionShownReceived:    make(map[int]bool),
-	requestAnswerReceived:    make([]PlayerAnswerRequest, 0),
-	playersAnswered      :    make([]int, 0),
-	startAcknowledgeReceived: make(map[int]bool),
-}
-
-func handleStart(w http.ResponseWriter, r *http.Request) {
-	log.Printf("call handleStart()")
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+func (gsi *GameStateInternal) stopTimers() {
+	if gsi.waitAnswerTimeout != nil {
+		gsi.waitAnswerTimeout.Stop()
+		gsi.waitAnswerTimeout = nil
 	}
+	if gsi.acknowledgeTimeout != nil {
+		gsi.acknowledgeTimeout.Stop()
+		gsi.acknowledgeTimeout = nil
+	}
+	if gsi.questionShownTimeout != nil {
+		gsi.questionShownTimeout.Stop()
+		gsi.questionShownTimeout = nil
+	}
+	if gsi.showAnswerTimeout != nil {
+		gsi.showAnswerTimeout.Stop()
+		gsi.showAnswerTimeout = nil
+	}
+	if gsi.startAcknowledgeTimeout != nil {
+		gsi.startAcknowledgeTimeout.Stop()
+		gsi.startAcknowledgeTimeout = nil
+	}
+}
 
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
-
-	if gameState.state != StateJoining {
-		http.Error(w, "Game is not in joining state", http.StatusBadRequest)
-		return
+// engineError отвечает клиенту ошибкой движка: неизвестный игрок - 404,
+// команда не в свое время (переход не разрешен в текущей фазе) - 409,
+// остальное - нарушение правил.
+func engineError(w http.ResponseWriter, err error) {
+	log.Printf("engine: %v", err)
+	var transitionErr *engine.TransitionError
+	switch {
+	case errors.Is(err, engine.ErrUnknownPlayer):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, engine.ErrPlayerExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.As(err, &transitionErr):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
 	}
-	gameState.state = StateStartAck
+}
 
-	// Выбираем случайного первого игрока
-	playerIds := make([]int, 0, len(gameState.players))
-	for id := range gameState.players {
-		if id < 1000 {            
-			playerIds = append(playerIds, id)
-		}
+// handleStateMachine отдает схему фаз игры в формате Graphviz:
+// dot -Tsvg -o game.svg
+func handleStateMachine(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	if err := engine.WriteDot(w); err != nil {
+		log.Printf("Error writing state machine: %v", err)
 	}
+}
 
-	if len(playerIds) == 0 {
-		http.Error(w, "No eligible players", http.StatusBadRequest)
+func (room *Room) handleStart(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[%s] call handleStart()", room.code)
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Случайный выбор первого игрока
-	idx := time.Now().UnixNano() % int64(len(playerIds))
-
-	gameState.currentPlayerId = playerIds[idx]
-
-	gameState.roundNum = 1
-	gameState.gameStartTime = time.Now().UTC()
-
-	log.Printf("currentPlayerId is %d", gameState.currentPlayerId)
+	room.do(func() {
+		events, err := room.game.Start()
+		if err != nil {
+			engineError(w, err)
+			return
+		}
+		room.apply(events)
 
-	
-	// Инициализируем карту подтверждений для старта
-	gameStateInternal.startAcknowledgeReceived = make(map[int]bool)
-	
-	// Отправляем сообщение start и ждем подтверждений
-	gameState.broadcastMessage("start", map[string]interface{}{})
-	log.Printf("ws start")
-
-	// Таймаут для startacknowledge
-	if gameStateInternal.startAcknowledgeTimeout != nil {
-		gameStateInternal.startAcknowledgeTimeout.Stop()
-	}
-	gameStateInternal.startAcknowledgeTimeout = time.AfterFunc(30*time.Second, func() {
-		gameState.mu.Lock()
-		transitionToSelectQuestionForced()
-		gameState.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Game started"))
 	})
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Game started"))
 }
 
-func handleReset(w http.ResponseWriter, r *http.Request) {
-	log.Printf("call handleReset()")
+func (room *Room) handleReset(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[%s] call handleReset()", room.code)
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
+	room.do(func() {
+		// Очищаем папку package
+		os.RemoveAll(room.path("package"))
+		os.MkdirAll(room.path("package"), 0755)
 
-	// Очищаем папку package
-	os.RemoveAll("package")
-	os.MkdirAll("package", 0755)
+		// Очищаем папку players
+		os.RemoveAll(room.path("players"))
+		os.MkdirAll(room.path("players"), 0755)
 
-	// Очищаем папку players
-	os.RemoveAll("players")
-	os.MkdirAll("players", 0755)
+		// Очищаем состояние игры
+		room.game = engine.NewGame(nil)
+		room.gameState.players = make(map[int]*Player)
+		room.gameState.packageJson = nil
+		room.gameState.prompts = defaultPrompts
+		room.gameUsage.reset()
 
-	// Очищаем состояние игры
-	gameState.players = make(map[int]*Player)
-	gameState.nextNPCId = 100
-	gameState.answeredQuestions = make(map[string]bool)
-	gameState.state = StateJoining
-	gameState.currentPlayerId = 0
-	gameState.roundNum = 0
-	gameState.packageJson = nil
-
-	// Очищаем внутреннее состояние
-	gameStateInternal.acknowledgesReceived = make(map[int]bool)
-	gameStateInternal.questionShownReceived = make(map[int]bool)
-	gameStateInternal.requestAnswerReceived = make([]PlayerAnswerRequest, 0)
-	gameStateInternal.playersAnswered = make([]int, 0)
-	gameStateInternal.startAcknowledgeReceived = make(map[int]bool)
-	gameStateInternal.selectedQuestionId = ""
-	gameStateInternal.canAnswerTimestamp = 0
-
-	// Останавливаем все таймеры
-	if gameStateInternal.waitAnswerTimeout != nil {
-		gameStateInternal.waitAnswerTimeout.Stop()
-		gameStateInternal.waitAnswerTimeout = nil
-	}
-	if gameStateInternal.acknowledgeTimeout != nil {
-		gameStateInternal.acknowledgeTimeout.Stop()
-		gameStateInternal.acknowledgeTimeout = nil
-	}
-	if gameStateInternal.questionShownTimeout != nil {
-		gameStateInternal.questionShownTimeout.Stop()
-		gameStateInternal.questionShownTimeout = nil
-	}
-	if gameStateInternal.showAnswerTimeout != nil {
-		gameStateInternal.showAnswerTimeout.Stop()
-		gameStateInternal.showAnswerTimeout = nil
-	}
-	if gameStateInternal.startAcknowledgeTimeout != nil {
-		gameStateInternal.startAcknowledgeTimeout.Stop()
-		gameStateInternal.startAcknowledgeTimeout = nil
-	}
-
-	// Отправляем сообщение о сбросе через WebSocket
-	gameState.broadcastMessage("reset", map[string]interface{}{})
+		// Очищаем внутреннее состояние
+		room.gameStateInternal.npcAnswers = make(map[int]string)
+		room.gameStateInternal.answerInProgress = false
+		room.gameStateInternal.verdicts = nil
+		room.gameStateInternal.clarifyVerdict = nil
+		room.gameStateInternal.canAnswerTimestamp = 0
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Game reset successfully"))
-}
+		// Останавливаем все таймеры
+		room.gameStateInternal.stopTimers()
 
-func handleActualize(w http.ResponseWriter, r *http.Request) {
-	if (gameState.state == StateSelectQuestion) {
-		log.Printf("before send table")
-		table, _, _ := getQuestionsTable(gameState.roundNum - 1, true)
-		gameState.broadcastMessage("questionstable", map[string]interface{}{
-			"table": table,
-		})
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Actualized"))
-		return;
-	}
+		// Журнал, как и при загрузке пакета, начинается заново
+		room.events.restart()
+
+		// Отправляем сообщение о сбросе через WebSocket
+		room.gameState.broadcastMessage("reset", map[string]interface{}{})
 
-	if (gameState.state == StateWaitAnswer) {		
-		gameState.broadcastMessage("waitanswer", map[string]interface{}{
-			"playerId": gameStateInternal.waitAnswerWinner,
-		})
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Actualized"))
-		return;
-	}
-	
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Not actualized"))
+		w.Write([]byte("Game reset successfully"))
+	})
 }
 
 func getQuestionStringId(round int, theme int, question int) (string, error) {
@@ -959,557 +1039,516 @@ func getQuestionStringId(round int, theme int, question int) (string, error) {
 	return result, nil
 }
 
-func handleSelectQuestion(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleSelectQuestion(w http.ResponseWriter, r *http.Request, idPlayer int) {
 	log.Printf("call handleSelectQuestion")
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
-
-	if gameState.state != StateSelectQuestion {
-		http.Error(w, "Game is not in select-question state", http.StatusBadRequest)
-		return
-	}
-
-	err := r.ParseForm()
-	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
-		return
-	}
-
-	log.Printf("handleSelectQuestion query is ok")
-
-	idRoundString := r.FormValue("round")
-	idThemeString := r.FormValue("theme")
-	idQuestString := r.FormValue("question")
-	idPlayerString := r.FormValue("player")
-
-	if idRoundString == "" || idThemeString == "" || idQuestString == "" || idPlayerString == ""{
-		http.Error(w, "Missing parameters", http.StatusBadRequest)
-		return
-	}
-
-	idRound, _ := strconv.Atoi(idRoundString)
-	idTheme, _ := strconv.Atoi(idThemeString)
-	idQuest, _ := strconv.Atoi(idQuestString)
-	stringId, _ := getQuestionStringId(idRound, idTheme, idQuest)
-	idPlayer, _ := strconv.Atoi(idPlayerString)
-
-	log.Printf("handleSelectQuestion parameters parsed")
+	room.do(func() {
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form", http.StatusBadRequest)
+			return
+		}
 
-	// Проверяем что это текущий игрок
-	if idPlayer != gameState.currentPlayerId {
-		log.Printf("Not current player")
-		http.Error(w, "Not current player", http.StatusBadRequest)
-		return
-	}
+		idRoundString := r.FormValue("round")
+		idThemeString := r.FormValue("theme")
+		idQuestString := r.FormValue("question")
 
-	// Проверяем что вопрос еще не отвечен
-	if gameState.answeredQuestions[stringId] {
-		log.Printf("Question already answered")
-		http.Error(w, "Question already answered", http.StatusBadRequest)
-		return
-	}
+		if idRoundString == "" || idThemeString == "" || idQuestString == "" {
+			http.Error(w, "Missing parameters", http.StatusBadRequest)
+			return
+		}
 
-	gameStateInternal.selectedQuestionId = stringId
+		idRound, _ := strconv.Atoi(idRoundString)
+		idTheme, _ := strconv.Atoi(idThemeString)
+		idQuest, _ := strconv.Atoi(idQuestString)
+		stringId, _ := getQuestionStringId(idRound, idTheme, idQuest)
 
-	log.Printf("select question %s", stringId)
+		events, err := room.game.SelectQuestion(idPlayer, stringId)
+		if err != nil {
+			engineError(w, err)
+			return
+		}
+		room.apply(events)
 
-	// Отправляем оповещение
-	gameState.broadcastMessage("questionselected", map[string]interface{}{
-		"id":        stringId,
-		"isspecial": false,
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Question selected"))
 	})
-
-	gameState.state = StateQuestion
-	gameStateInternal.questionShownReceived = make(map[int]bool)
-	gameStateInternal.requestAnswerReceived = make([]PlayerAnswerRequest, 0)
-	gameStateInternal.playersAnswered       = make([]int, 0)
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Question selected"))
 }
 
-func transitionToSelectQuestionAfterStart() {
-	if gameState.state != StateStartAck {
+func (room *Room) handleStartAcknowledge(w http.ResponseWriter, r *http.Request, id int) {
+	log.Printf("[%s] call handleStartAcknowledge()", room.code)
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	allAcknowledged := true
-	for id, player := range gameState.players {
-		if !player.IsNPC && !gameStateInternal.startAcknowledgeReceived[id] {
-			allAcknowledged = false
-			log.Printf("player not started: %i", id)
-			break
+	room.do(func() {
+		// Если уже перешли — просто OK
+		if room.game.Phase() == engine.PhaseSelectQuestion {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Already started"))
+			log.Printf("Already started")
+			return
 		}
-	}
 
-	if allAcknowledged {
-		log.Printf("all player started")
-		transitionToSelectQuestionForced()
-	}
-}
+		events, err := room.game.AcknowledgeStart(id)
+		if err != nil {
+			engineError(w, err)
+			return
+		}
+		log.Printf("player acknoledged %d", id)
+		room.apply(events)
 
-func transitionToSelectQuestionForced() {
-	log.Printf("call transitionToSelectQuestionForced()")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Start acknowledged"))
+	})
+}
 
-	// предотвращаем повторные вызовы
-	if gameState.state != StateStartAck {
-		log.Printf("call transitionToSelectQuestionForced stopped because state is %s", gameState.state)
+func (room *Room) handleQuestionBeenShown(w http.ResponseWriter, r *http.Request, playerId int) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.state = StateSelectQuestion
+	room.do(func() {
+		room.apply(room.game.QuestionShown(playerId))
 
-	log.Printf("before send table")
-	table, _, _ := getQuestionsTable(gameState.roundNum - 1, true)
-	gameState.broadcastMessage("questionstable", map[string]interface{}{
-		"table": table,
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Question shown"))
 	})
-	log.Printf("ws table")
-
-	// ход NPC
-	if p := gameState.players[gameState.currentPlayerId]; p != nil && p.IsNPC {
-		go handleNPCTurn()
-	}
 }
 
-func handleStartAcknowledge(w http.ResponseWriter, r *http.Request) {
-	log.Printf("call handleStartAcknowledge()")
+func (room *Room) handleRequestAnswer(w http.ResponseWriter, r *http.Request, id int) {
+	log.Printf("[%s] call handleRequestAnswer()", room.code)
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
+	room.do(func() {
+		events, err := room.game.Buzz(id)
+		if err != nil {
+			engineError(w, err)
+			return
+		}
+		room.apply(events)
 
-	// Если уже перешли — просто OK
-	if gameState.state == StateSelectQuestion {
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Already started"))
-		log.Printf("Already started")
-		return
-	}
-
-	err := r.ParseForm()
-	if err != nil {
-		log.Printf("Error parsing form")
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
-		return
-	}
+		w.Write([]byte("Answer request received"))
+	})
+}
 
-	idStr := r.FormValue("id")
-	if idStr == "" {
-		log.Printf("Missing id")
-		http.Error(w, "Missing id", http.StatusBadRequest)
-		return
-	}
+// processNPCAnswers запускает для каждого NPC генерацию ответа. Если персонаж
+// решил отвечать, он жмет на кнопку через свою задержку после cananswer.
+func (room *Room) processNPCAnswers() {
+	questionId := room.game.Question()
+	question := room.getQuestion(questionId)
+	theme := room.getQuestionTheme(questionId)
+	price := room.getScore(questionId)
+	canAnswerTime := time.UnixMilli(room.gameStateInternal.canAnswerTimestamp)
+	prompts := room.gameState.prompts
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		log.Printf("Invalid id")
-		http.Error(w, "Invalid id", http.StatusBadRequest)
-		return
-	}
+	for _, player := range room.gameState.players {
+		if player.Role != engine.RoleNPC || player.NPCCharacter == nil {
+			continue
+		}
 
-	player, exists := gameState.players[id]
-	if !exists {
-		log.Printf("Player not found")
-		http.Error(w, "Player not found", http.StatusNotFound)
-		return
-	}
-	
-	if player.IsNPC {
-		log.Printf("NPC players don't need to acknowledge")
-		http.Error(w, "NPC players don't need to acknowledge", http.StatusBadRequest)
-		return
-	}
+		go func(npc *Player) {
+			answer := room.generateAnswer(prompts, npc, question, theme, price)
+			if !answer.Buzz {
+				log.Printf("NPC %d doesn't buzz", npc.ID)
+				return
+			}
 
-	// сохраняем ack
-	gameStateInternal.startAcknowledgeReceived[id] = true
-	log.Printf("player acknoledged %i", id)
+			time.Sleep(time.Until(canAnswerTime.Add(answer.Delay)))
 
-	// проверяем, не все ли уже ответили
-	transitionToSelectQuestionAfterStart()
+			room.post(func() {
+				if room.game.Question() != questionId {
+					return
+				}
+				events, err := room.game.Buzz(npc.ID)
+				if err != nil {
+					return
+				}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Start acknowledged"))
+				log.Printf("NPC %d buzzes with confidence %.2f", npc.ID, answer.Confidence)
+				room.gameStateInternal.npcAnswers[npc.ID] = answer.Answer
+				room.apply(events)
+			})
+		}(player)
+	}
 }
 
-func handleQuestionBeenShown(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// answerNPC отправляет заготовленный ответ NPC, выигравшего право ответа.
+func (room *Room) answerNPC(id int) {
+	questionId := room.game.Question()
+	answer := room.gameStateInternal.npcAnswers[id]
 
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
+	room.after(2*time.Second, func() {
+		if room.game.Phase() != engine.PhaseWaitAnswer || room.game.Answering() != id ||
+			room.game.Question() != questionId || room.gameStateInternal.answerInProgress {
+			return
+		}
 
-	err := r.ParseForm()
-	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
-		return
-	}
+		room.checkAndProcessAnswer(questionId, id, answer)
+	})
+}
 
-	playerIdStr := r.FormValue("playerId")
-	if playerIdStr == "" {
-		http.Error(w, "Missing playerId", http.StatusBadRequest)
+func (room *Room) handleAnswer(w http.ResponseWriter, r *http.Request, idPlayer int) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	playerId, err := strconv.Atoi(playerIdStr)
-	if err != nil {
-		http.Error(w, "Invalid playerId", http.StatusBadRequest)
-		return
-	}
+	room.do(func() {
+		if room.game.Phase() != engine.PhaseWaitAnswer {
+			http.Error(w, "Game is not in wait-answer state", http.StatusBadRequest)
+			return
+		}
 
-	gameStateInternal.questionShownReceived[playerId] = true
+		if room.gameStateInternal.answerInProgress {
+			http.Error(w, "Answer is already being checked", http.StatusBadRequest)
+			return
+		}
 
-	// Проверяем все ли реальные игроки просмотрели вопрос
-	allShown := true
-	for id, player := range gameState.players {
-		if !player.IsNPC {
-			if !gameStateInternal.questionShownReceived[id] {
-				allShown = false
-				break
-			}
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error parsing form", http.StatusBadRequest)
+			return
 		}
-	}
 
-	if allShown {
-		sendCanAnswer()
-	}
+		idQuest:= r.FormValue("id-quest")
+		text := r.FormValue("text")
+		log.Printf("[%s] call handleAnswer(%s, %d, %s)", room.code, idQuest, idPlayer, text)
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Question shown"))
-}
+		if idQuest == "" {
+			http.Error(w, "Missing parameters", http.StatusBadRequest)
+			return
+		}
 
-func sendCanAnswer() {
-	if gameStateInternal.questionShownTimeout != nil {
-		gameStateInternal.questionShownTimeout.Stop()
-	}
+		// проверка моделью дорогая, чужой ответ отсекаем сразу
+		if idPlayer != room.game.Answering() || idQuest != room.game.Question() {
+			engineError(w, engine.ErrNotYourTurn)
+			return
+		}
 
-	// Время когда всем станет доступна кнопка
-	canAnswerTime := time.Now().UTC().Add(500 * time.Millisecond)
-	gameStateInternal.canAnswerTimestamp = canAnswerTime.Unix() * 1000
+		room.checkAndProcessAnswer(idQuest, idPlayer, text)
 
-	gameState.broadcastMessage("cananswer", map[string]interface{}{
-		"timestamp": gameStateInternal.canAnswerTimestamp,
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Answer processed"))
 	})
 }
 
-func handleRequestAnswer(w http.ResponseWriter, r *http.Request) {
-	log.Printf("call handleRequestAnswer()")
-
+func (room *Room) handleTimerDone(w http.ResponseWriter, r *http.Request, idPlayer int) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
+	room.do(func() {
+		log.Printf("[%s] call handleTimerDone(%d)", room.code, idPlayer)
 
-	if gameState.state != StateQuestion {
-		http.Error(w, "Game is not in question state", http.StatusBadRequest)
-		log.Printf("call handleRequestAnswer stopped because state is %s", gameState.state)
-		return
-	}
-
-	gameState.broadcastMessage("stoptimer", map[string]interface{}{})
+		room.apply(room.game.Pass(idPlayer))
 
-	err := r.ParseForm()
-	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
-		return
-	}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Answer processed"))
+	})
+}
 
-	playerStr := r.FormValue("player")
+// checkAndProcessAnswer вызывается из игрового цикла. Сама проверка моделью
+// идет в отдельной горутине.
+func (room *Room) checkAndProcessAnswer(idQuest string, idPlayer int, answerText string) {
+	log.Printf("[%s] call checkAndProcessAnswer(%s, %d, %s)", room.code, idQuest, idPlayer, answerText)
 
-	if playerStr == "" {
-		http.Error(w, "Missing parameters", http.StatusBadRequest)
-		return
+	// уточнение проверяем вместе с первой попыткой
+	if pending := room.gameStateInternal.clarifyVerdict; pending != nil && pending.PlayerId == idPlayer && pending.IdQuest == idQuest {
+		answerText = pending.Answer + " " + answerText
+		room.gameStateInternal.clarifyVerdict = nil
+		if room.gameStateInternal.waitAnswerTimeout != nil {
+			room.gameStateInternal.waitAnswerTimeout.Stop()
+			room.gameStateInternal.waitAnswerTimeout = nil
+		}
 	}
 
-	id, err := strconv.Atoi(playerStr)
-	if err != nil {
-		http.Error(w, "Invalid id", http.StatusBadRequest)
-		return
-	}
+	question := room.getQuestion(idQuest)
+	expectedAnswer := room.getAnswer(idQuest)
+	
+	// Get host player and their character
+	hostPlayer := room.host()
+	hostName := "Ведущий" // Default fallback
+	hostDescription := "(описание ведущего)"
 
-	// Сохраняем запрос
-	gameStateInternal.requestAnswerReceived = append(gameStateInternal.requestAnswerReceived, PlayerAnswerRequest{
-		playerId:  id,
-		timestamp: 0,
-	})
-	if !contains(gameStateInternal.playersAnswered, id) {
-		gameStateInternal.playersAnswered = append(gameStateInternal.playersAnswered, id)
+	if hostPlayer != nil && hostPlayer.NPCCharacter != nil {
+		hostName = hostPlayer.NPCCharacter.Name
+		hostDescription = hostPlayer.NPCCharacter.HostPrompt
 	}
-	log.Printf("playersAnswered = %s", gameStateInternal.playersAnswered)
 	
+	room.gameState.broadcastMessage("playertalk", map[string]interface{}{
+		"playerId": idPlayer,
+		"text":   answerText,
+	})
 
-	// Если это первый запрос, запускаем таймер для обработки
-	if len(gameStateInternal.requestAnswerReceived) == 1 {
-		time.AfterFunc(3*time.Second, func() {
-			gameState.mu.Lock()
-			processAnswerRequests()
-			gameState.mu.Unlock()
-		})
-	}
+	// Пока модель проверяет ответ и стримит речь ведущего, игровой цикл
+	// не ждет: проверка идет в своей горутине, а вердикт возвращается через post
+	room.gameStateInternal.answerInProgress = true
+	prompts := room.gameState.prompts
+
+	go func() {
+		log.Printf("send to claude (%s, %s, %s, %s, %s)", hostName, hostDescription, question, answerText, expectedAnswer)
+		streamId := atomic.AddInt64(&hostTalkStreamId, 1)
+		var claudeAnswer *siclo.ValidationResult
+		if room.gameUsage.overBudget() {
+			log.Printf("game budget exceeded, validating locally")
+			claudeAnswer = validateLocally(answerText, expectedAnswer)
+		} else {
+			var err error
+			claudeAnswer, err = siclo.ValidateAnswerStream(prompts, hostName, hostDescription, question, answerText, expectedAnswer,
+				func(delta string) {
+					room.gameState.broadcastMessage("hosttalkdelta", map[string]interface{}{
+						"streamId": streamId,
+						"delta":    delta,
+					})
+				})
+			if err != nil {
+				log.Printf("siclo.ValidateAnswer failed: %v", err)
+				claudeAnswer = validateLocally(answerText, expectedAnswer)
+			} else if claudeAnswer.Injection {
+				log.Printf("prompt injection attempt by player %d: %q, validated locally", idPlayer, answerText)
+			} else {
+				room.gameUsage.record(idPlayer, hostName, claudeAnswer.Usage, claudeAnswer.Cached)
+				if claudeAnswer.Redacted {
+					log.Printf("host justification named the expected answer, redacted")
+				}
+			}
+		}
+		result := claudeAnswer.Result;
+		hostSpeak := claudeAnswer.Justification;
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Answer request received"))
-}
+		log.Printf("claudeAnswer is %t", result)
+		log.Printf("claudeAnswer is %s", hostSpeak)
 
-func processAnswerRequests() {
-	log.Printf("call processAnswerRequests()")
+		room.post(func() {
+			room.gameStateInternal.answerInProgress = false
 
-	if gameState.state != StateQuestion {
-		log.Printf("call processAnswerRequests stopped because state is %s", gameState.state)
-		return
-	}
+			// пока шла проверка, игру могли сбросить
+			if room.game.Phase() != engine.PhaseWaitAnswer || room.game.Question() != idQuest {
+				log.Printf("answer check dropped because state is %s", room.game.Phase())
+				return
+			}
 
-	if (len(gameStateInternal.requestAnswerReceived) == 0) {
-		log.Printf("no more people answered")
-		return
-	}
+			if hostSpeak != "" {
+				room.gameState.broadcastMessage("hosttalk", map[string]interface{}{
+					"streamId": streamId,
+					"text":     hostSpeak,
+				})
+			}
 
-	// Находим random player who answered
-	idx := time.Now().UnixNano() % int64(len(gameStateInternal.requestAnswerReceived))
-	gameStateInternal.waitAnswerWinner = gameStateInternal.requestAnswerReceived[idx].playerId
-	log.Printf("winnerId %d", gameStateInternal.waitAnswerWinner)
+			verdict := &Verdict{
+				IdQuest:    idQuest,
+				PlayerId:   idPlayer,
+				HostName:   hostName,
+				HostPrompt: hostDescription,
+				Question:   question,
+				Expected:   expectedAnswer,
+				Answer:     answerText,
+				Result:     *claudeAnswer,
+			}
 
-	if gameStateInternal.waitAnswerWinner != -1 {
-		gameState.state = StateWaitAnswer
-		gameState.broadcastMessage("waitanswer", map[string]interface{}{
-			"playerId": gameStateInternal.waitAnswerWinner,
+			room.submit(verdict)
 		})
-
-		// Исключаем победителя
-		gameStateInternal.requestAnswerReceived = append(
-			gameStateInternal.requestAnswerReceived[:idx],
-            gameStateInternal.requestAnswerReceived[idx+1:]...)
-	}
-}
-
-func processNPCAnswers() {
-
+	}()
 }
 
-func handleAnswer(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
-
-	if gameState.state != StateWaitAnswer {
-		http.Error(w, "Game is not in wait-answer state", http.StatusBadRequest)
-		return
-	}
-
-	err := r.ParseForm()
-	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
-		return
-	}
+// requestClarification дает игроку короткое окно, чтобы дополнить ответ.
+// Если он не успел, ответ считается неверным.
+func (room *Room) requestClarification(verdict *Verdict) {
+	log.Printf("player %d is asked to clarify %q", verdict.PlayerId, verdict.Answer)
 
-	idQuest:= r.FormValue("id-quest")
-	idPlayerStr := r.FormValue("id-player")
-	text := r.FormValue("text")
-	log.Printf("call handleAnswer(%s, %s, %s)", idQuest, idPlayerStr, text)
-
-	if idQuest == "" || idPlayerStr == "" {
-		http.Error(w, "Missing parameters", http.StatusBadRequest)
-		return
-	}
+	room.gameState.broadcastMessage("clarify", map[string]interface{}{
+		"idQuest":  verdict.IdQuest,
+		"playerId": verdict.PlayerId,
+		"seconds":  int(clarifyWindow.Seconds()),
+	})
 
-	idPlayer, err := strconv.Atoi(idPlayerStr)
-	if err != nil {
-		http.Error(w, "Invalid idPlayer", http.StatusBadRequest)
-		return
-	}
+	room.gameStateInternal.waitAnswerTimeout = room.awaitClarification(verdict, clarifyWindow)
+}
 
-	checkAndProcessAnswer(idQuest, idPlayer, text)
+// awaitClarification засчитывает исходный вердикт, если за d игрок не уточнил ответ.
+func (room *Room) awaitClarification(verdict *Verdict, d time.Duration) *time.Timer {
+	room.gameStateInternal.deadline = time.Now().Add(d)
+	return room.after(d, func() {
+		if room.gameStateInternal.clarifyVerdict != verdict || room.gameStateInternal.answerInProgress {
+			return
+		}
+		room.gameStateInternal.clarifyVerdict = nil
+		room.gameStateInternal.waitAnswerTimeout = nil
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Answer processed"))
+		log.Printf("player %d did not clarify in time", verdict.PlayerId)
+		room.submit(verdict)
+	})
 }
 
-func handleTimerDone(w http.ResponseWriter, r *http.Request) {
+func (room *Room) handleAppeal(w http.ResponseWriter, r *http.Request, idPlayer int) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	gameState.mu.Lock()
-	defer gameState.mu.Unlock()
-
 	err := r.ParseForm()
 	if err != nil {
 		http.Error(w, "Error parsing form", http.StatusBadRequest)
 		return
 	}
 
-	idPlayerStr := r.FormValue("id-player")
-	log.Printf("call handleTimerDone(%s)", idPlayerStr)
+	idQuest := r.FormValue("id-quest")
+	log.Printf("[%s] call handleAppeal(%s, %d)", room.code, idQuest, idPlayer)
 
-	if idPlayerStr == "" {
+	if idQuest == "" {
 		http.Error(w, "Missing parameters", http.StatusBadRequest)
 		return
 	}
 
-	idPlayer, err := strconv.Atoi(idPlayerStr)
-	if err != nil {
-		http.Error(w, "Invalid idPlayer", http.StatusBadRequest)
+	// verdict остается nil, если оспорить нельзя
+	var verdict *Verdict
+	var prompts *siclo.Prompts
+	room.do(func() {
+		found := room.findVerdict(idQuest, idPlayer)
+		if found == nil {
+			http.Error(w, "Verdict not found", http.StatusNotFound)
+			return
+		}
+		if found.Appealed {
+			http.Error(w, "Verdict already appealed", http.StatusBadRequest)
+			return
+		}
+		if time.Since(found.Time) > appealWindow {
+			http.Error(w, "Appeal window is over", http.StatusBadRequest)
+			return
+		}
+		// спорить с засчитанным ответом игроку незачем
+		if found.Result.Result {
+			http.Error(w, "Only wrong verdicts can be appealed", http.StatusBadRequest)
+			return
+		}
+		found.Appealed = true
+		verdict = found
+		prompts = room.gameState.prompts
+	})
+	if verdict == nil {
 		return
 	}
-	if !contains(gameStateInternal.playersAnswered, idPlayer) {
-		gameStateInternal.playersAnswered = append(gameStateInternal.playersAnswered, idPlayer)
-	}
-
-	if (isAllPlayersAnswered()) {
-		showAnswer()
-	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Answer processed"))
-}
-
-func checkAndProcessAnswer(idQuest string, idPlayer int, answerText string) bool {
-	log.Printf("call checkAndProcessAnswer(%s, %d, %s)", idQuest, idPlayer, answerText)
-
-	question := getQuestion(idQuest)
-	expectedAnswer := getAnswer(idQuest)
-	
-	// Get host player (ID 1000) and their character
-	hostPlayer, exists := gameState.players[1000]
-	hostName := "Ведущий" // Default fallback
-	hostDescription := "(описание ведущего)"
-
-	if exists && hostPlayer.NPCCharacter != nil {
-		hostName = hostPlayer.NPCCharacter.Name
-		hostDescription = hostPlayer.NPCCharacter.HostPrompt
-	}
-	
-	claudeAnswer, _ := siclo.ValidateAnswer(hostName, "Пиши коротко, не называя то что написано на карточке ответа. " + hostDescription, 
-		question, answerText, expectedAnswer);
-	log.Printf("send to claude (%s, %s, %s, %s, %s)", hostName, "Пиши коротко, не называя то что написано на карточке ответа. " + hostDescription, 
-		question, answerText, expectedAnswer)
-	result := claudeAnswer.Result;
-	hostSpeak := claudeAnswer.Justification;
 
-	log.Printf("claudeAnswer is %b", result)
-	log.Printf("claudeAnswer is %s", hostSpeak)
+	// повторная проверка долгая, игровой цикл на это время не держим
+	review := room.reviewVerdict(verdict, prompts)
 
-	queueIsEmpty := len(gameStateInternal.requestAnswerReceived) == 0
-	done := isAllPlayersAnswered()
-
-	gameState.broadcastMessage("validated", map[string]interface{}{
-		"idQuest":  idQuest,
-		"playerId": idPlayer,
-		"result":   result,
-		"unfreeze": queueIsEmpty,
-	})
+	room.do(func() {
+		flipped := review.Result != verdict.Result.Result
+		scoreChange := 0
+		if flipped {
+			// отменяем изменение очков из checkAndProcessAnswer
+			if err := room.game.AdjustScore(verdict.PlayerId, -verdict.ScoreChange); err == nil {
+				scoreChange = -verdict.ScoreChange
+			}
+		}
+		log.Printf("appeal of player %d on %s: result %t, flipped %t", idPlayer, idQuest, review.Result, flipped)
+
+		response := map[string]interface{}{
+			"idQuest":     idQuest,
+			"playerId":    idPlayer,
+			"result":      review.Result,
+			"flipped":     flipped,
+			"scoreChange": scoreChange,
+			"text":        review.Justification,
+		}
+		room.gameState.broadcastMessage("appealresult", response)
 
-	gameState.broadcastMessage("playertalk", map[string]interface{}{
-		"playerId": idPlayer,
-		"text":   answerText,
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
 	})
+}
 
-	gameState.broadcastMessage("hosttalk", map[string]interface{}{
-		"text":   hostSpeak,
-	})
+func (room *Room) findVerdict(idQuest string, idPlayer int) *Verdict {
+	for _, verdict := range room.gameStateInternal.verdicts {
+		if verdict.IdQuest == idQuest && verdict.PlayerId == idPlayer {
+			return verdict
+		}
+	}
+	return nil
+}
 
-	// ответ верный
-	if (result) {
-		gameState.currentPlayerId = idPlayer
-		gameState.players[idPlayer].Score += getScore(gameStateInternal.selectedQuestionId);
-		showAnswer()
-		return result
+// reviewVerdict проверяет оспоренный ответ еще раз, строже. Если модель
+// недоступна, решает локальная проверка.
+func (room *Room) reviewVerdict(verdict *Verdict, prompts *siclo.Prompts) *siclo.ValidationResult {
+	if room.gameUsage.overBudget() {
+		log.Printf("game budget exceeded, reviewing appeal locally")
+		return validateLocally(verdict.Answer, verdict.Expected)
 	}
-	
-	gameState.players[idPlayer].Score -= getScore(gameStateInternal.selectedQuestionId);
 
-	// остались люди, кто нажал на кнопку
-	if (!queueIsEmpty) {
-		log.Printf("ball to other player")
-		gameState.state = StateQuestion
-		processAnswerRequests()
-		return result
+	review, err := siclo.ReviewAnswer(prompts, verdict.HostName, verdict.HostPrompt, verdict.Question, verdict.Answer, verdict.Expected, verdict.Result)
+	if err != nil {
+		log.Printf("siclo.ReviewAnswer failed: %v", err)
+		return validateLocally(verdict.Answer, verdict.Expected)
 	}
-	
-	// остались люди кто еще не попробовал ответить
-	if (!done) {
-		gameState.state = StateQuestion
-		return result
+	if review.Injection {
+		log.Printf("prompt injection attempt in appeal of player %d: %q", verdict.PlayerId, verdict.Answer)
+		return review
 	}
 
-	showAnswer()
-
-	return result
+	room.gameUsage.record(verdict.PlayerId, verdict.HostName, review.Usage, false)
+	return review
 }
 
-func isAllPlayersAnswered() bool {
-	log.Printf("playersAnswered = %d, players = %d", len(gameStateInternal.playersAnswered), numberOfRealPlayers())
-	return len(gameStateInternal.playersAnswered) == numberOfRealPlayers()
-}
-
-func showAnswer() {
-	log.Printf("call showAnswer()")
-
-	gameState.broadcastMessage("showanswer", map[string]interface{}{
-		"idQuest": gameStateInternal.selectedQuestionId,
-	})
+// hostTalk генерирует реплику ведущего в отдельной горутине, чтобы не держать
+// игру во время запроса к модели, и рассылает ее как hosttalk.
+func (room *Room) hostTalk(generate func(prompts *siclo.Prompts, host siclo.Character) (*siclo.HostLine, error)) {
+	hostPlayer := room.host()
+	if hostPlayer == nil || hostPlayer.NPCCharacter == nil || room.gameUsage.overBudget() {
+		return
+	}
+	host := hostPlayer.NPCCharacter.toSiclo()
+	prompts := room.gameState.prompts
 
-	gameState.answeredQuestions[gameStateInternal.selectedQuestionId] = true
+	go func() {
+		line, err := generate(prompts, host)
+		if err != nil {
+			log.Printf("host line generation failed: %v", err)
+			return
+		}
+		room.gameUsage.record(0, host.Name, line.Usage, false)
 
-	// переходим к вопросам
-	time.AfterFunc(5 * time.Second, func() {
-		transitionToSelectQuestion()
-	})
+		room.gameState.broadcastMessage("hosttalk", map[string]interface{}{
+			"text": line.Text,
+		})
+	}()
 }
 
-func transitionToSelectQuestion() {
-	log.Printf("call transitionToSelectQuestion()")
-
-	gameState.state = StateSelectQuestion
-
-	log.Printf("before send table")
-	table, isempty, _  := getQuestionsTable(gameState.roundNum - 1, true)
-
-	if (isempty) {
-		log.Printf("next round")
-		if isThereNextRound(gameState.roundNum) {
-			gameState.roundNum++
-		} else {
-			gameState.state = StateUnknown
+// npcTurn - NPC выбирает вопрос: самый дешевый из оставшихся в раунде, после
+// паузы, чтобы ход было видно. Вызывается из игрового цикла.
+func (room *Room) npcTurn(id int) {
+	room.after(npcSelectDelay, func() {
+		open := room.game.OpenQuestions()
+		if len(open) == 0 {
 			return
 		}
-	}
-	table, isempty, _  = getQuestionsTable(gameState.roundNum - 1, true)
-
-	gameState.broadcastMessage("questionstable", map[string]interface{}{
-		"table": table,
+		// ход мог уже смениться, тогда движок откажет и это нормально
+		events, err := room.game.SelectQuestion(id, open[0])
+		if err != nil {
+			log.Printf("[%s] NPC %d turn dropped: %v", room.code, id, err)
+			return
+		}
+		room.apply(events)
 	})
-	log.Printf("ws table")
-
-	return
 }
 
-func handleNPCTurn() {
-	
-}
-
-func getScore(questionId string) int {
+func (room *Room) getScore(questionId string) int {
 	var a, b, c int
 	_, err := fmt.Sscanf(questionId, "%d_%d_%d", &a, &b, &c)
-	priceStr := getQuestionPrice(a-1, b-1, c-1)
+	priceStr := room.getQuestionPrice(a-1, b-1, c-1)
 	price, err := strconv.Atoi(priceStr)
 	if ( err != nil) {
 		return 0;
@@ -1518,10 +1557,10 @@ func getScore(questionId string) int {
 	return price
 }
 
-func getQuestion(questionId string) string {
+func (room *Room) getQuestion(questionId string) string {
 	var a, b, c int
 	_, err := fmt.Sscanf(questionId, "%d_%d_%d", &a, &b, &c)
-	question := getQuestionText(a-1, b-1, c-1)
+	question := room.getQuestionText(a-1, b-1, c-1)
 	if ( err != nil) {
 		log.Printf("question questionId is: %s", "err!")
 		return "";
@@ -1530,17 +1569,26 @@ func getQuestion(questionId string) string {
 	return question
 }
 
-func getAnswer(questionId string) string {
+func (room *Room) getQuestionTheme(questionId string) string {
+	var a, b, c int
+	_, err := fmt.Sscanf(questionId, "%d_%d_%d", &a, &b, &c)
+	if ( err != nil) {
+		return "";
+	}
+	return room.getThemeName(a-1, b-1)
+}
+
+func (room *Room) getAnswer(questionId string) string {
 	var a, b, c int
 	_, err := fmt.Sscanf(questionId, "%d_%d_%d", &a, &b, &c)
 	if ( err != nil) {
 		return "";
 	}
 	
-	question := getQuestionAnswer1(a-1, b-1, c-1)
+	question := room.getQuestionAnswer1(a-1, b-1, c-1)
 	// If getQuestionAnswer1 didn't return a result, fallback to getQuestionAnswer2
 	if question == "" {
-		question = getQuestionAnswer2(a-1, b-1, c-1)
+		question = room.getQuestionAnswer2(a-1, b-1, c-1)
 	}
 	
 	log.Printf("question answer is: %s", question)
@@ -1549,25 +1597,23 @@ func getAnswer(questionId string) string {
 
 // Функции для работы с вопросами
 
-func getQuestionsTable(roundNum int, filterAnswered bool) (string, bool, error) {
+func (room *Room) getQuestionsTable(roundNum int, filterAnswered bool) (string, bool, error) {
 	var result []string
 	isempty := true
 
-	log.Printf("answered: %+v", gameState.answeredQuestions)
-
-	themesCount := getThemesCountForRound(roundNum)
+	themesCount := room.getThemesCountForRound(roundNum)
 	for i := 0; i < themesCount; i++ {
-		result = append(result, getThemeName(roundNum, i))
-		questionsCount := getQuestionsCount(roundNum, i)
+		result = append(result, room.getThemeName(roundNum, i))
+		questionsCount := room.getQuestionsCount(roundNum, i)
 		for j := 0; j < 10; j++ {
 			if (j < questionsCount) {
 				questId, _ := getQuestionStringId(roundNum+1, i+1, j+1)
-				if (filterAnswered && gameState.answeredQuestions[questId]) {
+				if (filterAnswered && room.game.Answered(questId)) {
 					result = append(result, "-")
 				} else {
 					isempty = false
 					log.Printf("not empty: %d %d %d", roundNum, i, j)
-					result = append(result, getQuestionPrice(roundNum, i, j))
+					result = append(result, room.getQuestionPrice(roundNum, i, j))
 				}
 			} else {
 				result = append(result, "")
@@ -1579,8 +1625,8 @@ func getQuestionsTable(roundNum int, filterAnswered bool) (string, bool, error)
 	return strings.Join(result, "|"), isempty, nil
 }
 
-func getThemesCountForRound(roundNum int) int {
-	raw, _ := json.Marshal(gameState.packageJson)
+func (room *Room) getThemesCountForRound(roundNum int) int {
+	raw, _ := json.Marshal(room.gameState.packageJson)
 	jsonString := string(raw)
 
 	path := fmt.Sprintf("rounds.0.round.%d.themes.0.theme.#", roundNum)
@@ -1588,16 +1634,25 @@ func getThemesCountForRound(roundNum int) int {
 	return int(count)
 }
 
-func getThemeName(roundNum int, themeNum int) string {
-	raw, _ := json.Marshal(gameState.packageJson)
+func (room *Room) getRoundThemes(roundNum int) []string {
+	themesCount := room.getThemesCountForRound(roundNum)
+	themes := make([]string, 0, themesCount)
+	for i := 0; i < themesCount; i++ {
+		themes = append(themes, room.getThemeName(roundNum, i))
+	}
+	return themes
+}
+
+func (room *Room) getThemeName(roundNum int, themeNum int) string {
+	raw, _ := json.Marshal(room.gameState.packageJson)
 	jsonString := string(raw)
 
 	path := fmt.Sprintf("rounds.0.round.%d.themes.0.theme.%d.@name", roundNum, themeNum)
 	return gjson.Get(jsonString, path).String()
 }
 
-func getQuestionsCount(roundNum int, themeNum int) int {
-	raw, _ := json.Marshal(gameState.packageJson)
+func (room *Room) getQuestionsCount(roundNum int, themeNum int) int {
+	raw, _ := json.Marshal(room.gameState.packageJson)
 	jsonString := string(raw)
 
 	path := fmt.Sprintf("rounds.0.round.%d.themes.0.theme.%d.questions.0.question.#", roundNum, themeNum)
@@ -1605,16 +1660,16 @@ func getQuestionsCount(roundNum int, themeNum int) int {
 	return int(count)
 }
 
-func getQuestionPrice(roundNum int, themeNum int, questNum int) string {
-	raw, _ := json.Marshal(gameState.packageJson)
+func (room *Room) getQuestionPrice(roundNum int, themeNum int, questNum int) string {
+	raw, _ := json.Marshal(room.gameState.packageJson)
 	jsonString := string(raw)
 
 	path := fmt.Sprintf("rounds.0.round.%d.themes.0.theme.%d.questions.0.question.%d.@price", roundNum, themeNum, questNum)
 	return gjson.Get(jsonString, path).String()
 }
 
-func getQuestionText(roundNum int, themeNum int, questNum int) string {
-    raw, err := json.Marshal(gameState.packageJson)
+func (room *Room) getQuestionText(roundNum int, themeNum int, questNum int) string {
+    raw, err := json.Marshal(room.gameState.packageJson)
     if err != nil {
         return ""
     }
@@ -1659,8 +1714,8 @@ func getQuestionText(roundNum int, themeNum int, questNum int) string {
     return strings.Join(parts, " ")
 }
 
-func getQuestionAnswer1(roundNum int, themeNum int, questNum int) string {
-	raw, _ := json.Marshal(gameState.packageJson)
+func (room *Room) getQuestionAnswer1(roundNum int, themeNum int, questNum int) string {
+	raw, _ := json.Marshal(room.gameState.packageJson)
 	jsonString := string(raw)
 
 	path := fmt.Sprintf(
@@ -1671,8 +1726,8 @@ func getQuestionAnswer1(roundNum int, themeNum int, questNum int) string {
 	return gjson.Get(jsonString, path).String()
 }
 
-func getQuestionAnswer2(roundNum int, themeNum int, questNum int) string {
-	raw, _ := json.Marshal(gameState.packageJson)
+func (room *Room) getQuestionAnswer2(roundNum int, themeNum int, questNum int) string {
+	raw, _ := json.Marshal(room.gameState.packageJson)
 	jsonString := string(raw)
 
 	path := fmt.Sprintf(
@@ -1687,10 +1742,8 @@ func getQuestionsForTheme(theme Theme) []Question {
 	return theme.Questions
 }
 
-func checkAnswer(idQuest int, answer string) (bool, string) {
-	gameState.mu.RLock()
-	packageJson := gameState.packageJson
-	gameState.mu.RUnlock()
+func (room *Room) checkAnswer(idQuest int, answer string) (bool, string) {
+	packageJson := room.gameState.packageJson
 
 	// Используем вспомогательную функцию для поиска вопроса
 	questionMap, correctAnswer, _ := findQuestionById(packageJson, idQuest)
@@ -1709,10 +1762,8 @@ func checkAnswer(idQuest int, answer string) (bool, string) {
 	return result, comment
 }
 
-func getPointsForQuestion(idQuest int) int {
-	gameState.mu.RLock()
-	packageJson := gameState.packageJson
-	gameState.mu.RUnlock()
+func (room *Room) getPointsForQuestion(idQuest int) int {
+	packageJson := room.gameState.packageJson
 
 	// Используем вспомогательную функцию для поиска вопроса
 	questionMap, _, _ := findQuestionById(packageJson, idQuest)
@@ -1730,28 +1781,142 @@ func getPointsForQuestion(idQuest int) int {
 	return price
 }
 
-type NPCAnswer struct {
-	HaveAnswer bool
-	Answer     string
+func (room *Room) generateAnswer(prompts *siclo.Prompts, npc *Player, question string, theme string, price int) siclo.NPCAnswer {
+	if npc.NPCCharacter == nil || room.gameUsage.overBudget() {
+		return siclo.NPCAnswer{}
+	}
+
+	answer, err := siclo.GenerateAnswer(prompts, npc.NPCCharacter.toSiclo(), question, theme, price)
+	if err != nil {
+		log.Printf("siclo.GenerateAnswer failed for NPC %d: %v", npc.ID, err)
+		return siclo.NPCAnswer{}
+	}
+	room.gameUsage.record(npc.ID, npc.NPCCharacter.Name, answer.Usage, false)
+
+	log.Printf("NPC %d answer: %+v", npc.ID, *answer)
+	return *answer
+}
+
+// validateLocally - проверка без модели.
+func validateLocally(answerText string, expectedAnswer string) *siclo.ValidationResult {
+	return &siclo.ValidationResult{
+		Result: siclo.MatchLocally(answerText, expectedAnswer),
+	}
+}
+
+type UsageStats struct {
+	Calls        int     `json:"calls"`
+	CachedCalls  int     `json:"cachedCalls"`
+	InputTokens  int64   `json:"inputTokens"`
+	OutputTokens int64   `json:"outputTokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// add учитывает запрос. Вердикт из кеша считается только как вызов:
+// токенов на него не потрачено.
+func (s *UsageStats) add(usage siclo.Usage, cached bool) {
+	s.Calls++
+	if cached {
+		s.CachedCalls++
+		return
+	}
+	s.InputTokens += usage.InputTokens
+	s.OutputTokens += usage.OutputTokens
+	s.Cost += usage.Cost()
+}
+
+// GameUsage - расход токенов за игру. У него свой мьютекс, потому что
+// реплики ведущего считаются в горутинах вне игрового цикла.
+type GameUsage struct {
+	mu          sync.Mutex
+	Total       UsageStats             `json:"total"`
+	ByPlayer    map[int]*UsageStats    `json:"byPlayer"`
+	ByCharacter map[string]*UsageStats `json:"byCharacter"`
+	Budget      float64                `json:"budget"`
+}
+
+func newGameUsage() *GameUsage {
+	return &GameUsage{
+		ByPlayer:    make(map[int]*UsageStats),
+		ByCharacter: make(map[string]*UsageStats),
+	}
+}
+
+var gameBudget float64
+
+// record учитывает запрос к модели. playerId 0 - запрос не связан с игроком
+// (реплики ведущего).
+func (u *GameUsage) record(playerId int, character string, usage siclo.Usage, cached bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.Total.add(usage, cached)
+
+	if playerId != 0 {
+		if u.ByPlayer[playerId] == nil {
+			u.ByPlayer[playerId] = &UsageStats{}
+		}
+		u.ByPlayer[playerId].add(usage, cached)
+	}
+
+	if u.ByCharacter[character] == nil {
+		u.ByCharacter[character] = &UsageStats{}
+	}
+	u.ByCharacter[character].add(usage, cached)
+}
+
+func (u *GameUsage) overBudget() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return gameBudget > 0 && u.Total.Cost >= gameBudget
 }
 
-func generateAnswer(npc *Player) NPCAnswer {
-	return NPCAnswer{HaveAnswer: false}
+func (u *GameUsage) reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.Total = UsageStats{}
+	u.ByPlayer = make(map[int]*UsageStats)
+	u.ByCharacter = make(map[string]*UsageStats)
 }
 
-func isThereNextRound(currentRound int) bool {
-	gameState.mu.RLock()
-	packageJson := gameState.packageJson
-	gameState.mu.RUnlock()
+func (u *GameUsage) summary() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return fmt.Sprintf("%d calls (%d cached), %d input / %d output tokens, $%.4f",
+		u.Total.Calls, u.Total.CachedCalls, u.Total.InputTokens, u.Total.OutputTokens, u.Total.Cost)
+}
+
+func (room *Room) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	room.gameUsage.mu.Lock()
+	room.gameUsage.Budget = gameBudget
+	raw, err := json.Marshal(room.gameUsage)
+	room.gameUsage.mu.Unlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(raw)
+}
 
+// countRounds считает раунды пакета. В rounds лежат объекты с полем "round",
+// и каждый round может содержать массив раундов.
+func countRounds(packageJson map[string]interface{}) int {
 	rounds, ok := packageJson["rounds"].([]interface{})
 	if !ok {
-		return false
+		return 0
 	}
 
-	// В новой структуре rounds - это массив объектов с полем "round"
-	// Каждый round может содержать массив round элементов
-	// Считаем общее количество раундов
 	totalRounds := 0
 	for _, roundData := range rounds {
 		roundMap, ok := roundData.(map[string]interface{})
@@ -1764,8 +1929,27 @@ func isThereNextRound(currentRound int) bool {
 			totalRounds++ // Если round не массив, считаем как один раунд
 		}
 	}
+	return totalRounds
+}
 
-	return currentRound < totalRounds
+// packageBoard собирает для движка цены вопросов по раундам, как их
+// показывает getQuestionsTable.
+func (room *Room) packageBoard() engine.Board {
+	board := make(engine.Board, countRounds(room.gameState.packageJson))
+	for roundNum := range board {
+		board[roundNum] = make(map[string]int)
+		themesCount := room.getThemesCountForRound(roundNum)
+		for i := 0; i < themesCount; i++ {
+			questionsCount := room.getQuestionsCount(roundNum, i)
+			// в таблице помещается не больше 10 вопросов темы
+			for j := 0; j < questionsCount && j < 10; j++ {
+				questId, _ := getQuestionStringId(roundNum+1, i+1, j+1)
+				price, _ := strconv.Atoi(room.getQuestionPrice(roundNum, i, j))
+				board[roundNum][questId] = price
+			}
+		}
+	}
+	return board
 }
 
 // Вспомогательные функции
@@ -1950,31 +2134,3 @@ func withCORS(h http.Handler) http.Handler {
     })
 }
 
-func numberOfAllPlayers() int {
-	count := 0
-	for id := range gameState.players {
-		if id < 1000 {
-			count++
-		}
-	}
-	return count
-}
-
-func numberOfRealPlayers() int {
-	count := 0
-	for id := range gameState.players {
-		if id < 100 {
-			count++
-		}
-	}
-	return count
-}
-
-func contains(xs []int, x int) bool {
-    for _, v := range xs {
-        if v == x {
-            return true
-        }
-    }
-    return false
-}
\ No newline at end of file
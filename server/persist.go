@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sigo-server/engine"
+)
+
+// Снимок комнаты лежит в rooms/<код>/game.json. Игровой цикл пишет его после
+// каждой команды, если что-то изменилось, а при старте сервер поднимает из
+// снимков комнаты и заново заводит таймеры текущей фазы.
+
+const snapshotFile = "game.json"
+
+type roomSnapshot struct {
+	Code    string          `json:"code"`
+	Created time.Time       `json:"created"`
+	Game    engine.Snapshot `json:"game"`
+	Players []savedPlayer   `json:"players"`
+	NextId  int             `json:"nextId"`
+	// Deadline - когда сработает таймер текущей фазы
+	Deadline           time.Time `json:"deadline"`
+	CanAnswerTimestamp int64     `json:"canAnswerTimestamp"`
+	// Clarify - вердикт, по которому игрок сейчас уточняет ответ
+	Clarify    *Verdict       `json:"clarify,omitempty"`
+	NPCAnswers map[int]string `json:"npcAnswers,omitempty"`
+	// Verdicts - вынесенные вердикты, чтобы их можно было оспорить и после
+	// перезапуска
+	Verdicts []*Verdict `json:"verdicts,omitempty"`
+	Usage    savedUsage `json:"usage"`
+}
+
+type savedPlayer struct {
+	engine.Player
+	Character string `json:"character,omitempty"`
+}
+
+// savedUsage - счетчики GameUsage без мьютекса и бюджета.
+type savedUsage struct {
+	Total       UsageStats            `json:"total"`
+	ByPlayer    map[int]UsageStats    `json:"byPlayer,omitempty"`
+	ByCharacter map[string]UsageStats `json:"byCharacter,omitempty"`
+}
+
+// saved копирует счетчики для снимка.
+func (u *GameUsage) saved() savedUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	s := savedUsage{
+		Total:       u.Total,
+		ByPlayer:    make(map[int]UsageStats, len(u.ByPlayer)),
+		ByCharacter: make(map[string]UsageStats, len(u.ByCharacter)),
+	}
+	for id, stats := range u.ByPlayer {
+		s.ByPlayer[id] = *stats
+	}
+	for name, stats := range u.ByCharacter {
+		s.ByCharacter[name] = *stats
+	}
+	return s
+}
+
+// load возвращает счетчики из снимка.
+func (u *GameUsage) load(s savedUsage) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.Total = s.Total
+	u.ByPlayer = make(map[int]*UsageStats, len(s.ByPlayer))
+	for id, stats := range s.ByPlayer {
+		u.ByPlayer[id] = &stats
+	}
+	u.ByCharacter = make(map[string]*UsageStats, len(s.ByCharacter))
+	for name, stats := range s.ByCharacter {
+		u.ByCharacter[name] = &stats
+	}
+}
+
+func (room *Room) snapshot() roomSnapshot {
+	s := roomSnapshot{
+		Code:               room.code,
+		Created:            room.created,
+		Game:               room.game.Snapshot(),
+		NextId:             room.gameState.nextId,
+		Deadline:           room.gameStateInternal.deadline,
+		CanAnswerTimestamp: room.gameStateInternal.canAnswerTimestamp,
+		Clarify:            room.gameStateInternal.clarifyVerdict,
+		NPCAnswers:         room.gameStateInternal.npcAnswers,
+		Verdicts:           room.gameStateInternal.verdicts,
+		Usage:              room.gameUsage.saved(),
+	}
+	for _, p := range room.gameState.players {
+		saved := savedPlayer{Player: p.Player}
+		if p.NPCCharacter != nil {
+			saved.Character = p.NPCCharacter.Name
+		}
+		s.Players = append(s.Players, saved)
+	}
+	// порядок обхода map каждый раз другой, а persist сравнивает снимки по байтам
+	sort.Slice(s.Players, func(i, j int) bool {
+		return s.Players[i].ID < s.Players[j].ID
+	})
+	return s
+}
+
+// persist пишет снимок, если он изменился с прошлого раза. Вызывается из
+// игрового цикла.
+func (room *Room) persist() {
+	data, err := json.Marshal(room.snapshot())
+	if err != nil {
+		log.Printf("[%s] snapshot failed: %v", room.code, err)
+		return
+	}
+	if bytes.Equal(data, room.saved) {
+		return
+	}
+
+	// Пишем через временный файл, чтобы падение посреди записи не
+	// оставило половину снимка
+	tmp := room.path(snapshotFile + ".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("[%s] snapshot failed: %v", room.code, err)
+		return
+	}
+	if err := os.Rename(tmp, room.path(snapshotFile)); err != nil {
+		log.Printf("[%s] snapshot failed: %v", room.code, err)
+		return
+	}
+	room.saved = data
+}
+
+// restore поднимает комнаты из снимков в rooms/. Папки без снимка или с
+// испорченным снимком удаляются.
+func (reg *RoomRegistry) restore() {
+	entries, err := os.ReadDir("rooms")
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		dir := filepath.Join("rooms", entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+		if err != nil {
+			os.RemoveAll(dir)
+			continue
+		}
+
+		var s roomSnapshot
+		if err := json.Unmarshal(data, &s); err != nil || s.Code != entry.Name() {
+			log.Printf("[%s] broken snapshot, room removed: %v", entry.Name(), err)
+			os.RemoveAll(dir)
+			continue
+		}
+
+		room := newRoom(s.Code)
+		room.created = s.Created
+		room.saved = data
+
+		var restoreErr error
+		var phase engine.Phase
+		room.do(func() {
+			restoreErr = room.restore(s)
+			phase = room.game.Phase()
+		})
+		if restoreErr != nil {
+			log.Printf("[%s] broken snapshot, room removed: %v", s.Code, restoreErr)
+			room.close()
+			continue
+		}
+
+		reg.mu.Lock()
+		reg.rooms[s.Code] = room
+		reg.mu.Unlock()
+		log.Printf("[%s] room restored in phase %s", s.Code, phase)
+	}
+}
+
+// restore возвращает комнате игру из снимка. Вызывается из игрового цикла
+// только что созданной комнаты.
+func (room *Room) restore(s roomSnapshot) error {
+	if data, err := os.ReadFile(room.path("package/content.json")); err == nil {
+		var packageJson map[string]interface{}
+		if err := json.Unmarshal(data, &packageJson); err != nil {
+			return err
+		}
+		room.gameState.packageJson = packageJson
+
+		prompts, err := selectPrompts(packageJson)
+		if err != nil {
+			log.Printf("[%s] Warning: Failed to load prompts for package: %v", room.code, err)
+			prompts = defaultPrompts
+		}
+		room.gameState.prompts = prompts
+	}
+
+	nextId := s.NextId
+	for _, saved := range s.Players {
+		player := &Player{Player: saved.Player}
+		switch player.Role {
+		case engine.RolePlayer, engine.RoleNPC, engine.RoleHost, engine.RoleSpectator:
+		default:
+			return fmt.Errorf("player %d has unknown role %q", player.ID, player.Role)
+		}
+		if saved.Character != "" {
+			player.NPCCharacter = npcCharactersMap[strings.ToLower(saved.Character)]
+		}
+		room.gameState.players[player.ID] = player
+		if player.Role != engine.RoleSpectator {
+			if err := room.game.Join(&player.Player); err != nil {
+				return err
+			}
+		}
+		nextId = max(nextId, player.ID+1)
+	}
+	room.gameState.nextId = max(nextId, 1)
+
+	if err := room.game.Restore(s.Game); err != nil {
+		return err
+	}
+	room.gameStateInternal.deadline = s.Deadline
+	room.gameStateInternal.canAnswerTimestamp = s.CanAnswerTimestamp
+	room.gameStateInternal.clarifyVerdict = s.Clarify
+	if s.NPCAnswers != nil {
+		room.gameStateInternal.npcAnswers = s.NPCAnswers
+	}
+	room.gameStateInternal.verdicts = s.Verdicts
+	room.gameUsage.load(s.Usage)
+
+	room.resume()
+	return nil
+}
+
+// resume заводит таймеры и ходы NPC, которые шли в фазе на момент снимка.
+// Таймер, срок которого вышел, пока сервер лежал, срабатывает сразу.
+func (room *Room) resume() {
+	gsi := room.gameStateInternal
+	remaining := max(time.Until(gsi.deadline), 0)
+
+	switch room.game.Phase() {
+	case engine.PhaseStartAck:
+		gsi.startAcknowledgeTimeout = room.later(remaining, func() []engine.Event {
+			return room.game.StartTimeout()
+		})
+
+	case engine.PhaseSelectQuestion:
+		if p := room.gameState.players[room.game.CurrentPlayer()]; p != nil && p.Role == engine.RoleNPC {
+			room.npcTurn(p.ID)
+		}
+
+	case engine.PhaseQuestion:
+		if room.game.Buzzing() {
+			room.later(remaining, func() []engine.Event {
+				return room.game.ResolveBuzz()
+			})
+		} else if room.game.Opened() {
+			// ответы NPC, которые еще не нажали, генерируются заново
+			room.processNPCAnswers()
+		}
+
+	case engine.PhaseWaitAnswer:
+		id := room.game.Answering()
+		if p := room.gameState.players[id]; p != nil && p.Role == engine.RoleNPC {
+			room.answerNPC(id)
+		} else if gsi.clarifyVerdict != nil {
+			gsi.waitAnswerTimeout = room.awaitClarification(gsi.clarifyVerdict, remaining)
+		}
+
+	case engine.PhaseShowAnswer:
+		gsi.showAnswerTimeout = room.later(remaining, func() []engine.Event {
+			return room.game.NextTurn()
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/goldenpineappleofthesun/siclo"
+
+	"sigo-server/engine"
+)
+
+// TestSnapshotKeepsVerdictsAndUsage проверяет, что после перезапуска
+// вердикт можно оспорить, а расход модели не обнуляется.
+func TestSnapshotKeepsVerdictsAndUsage(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	room := newRoom("TEST")
+	t.Cleanup(room.close)
+	verdict := &Verdict{
+		IdQuest:     "1_1_1",
+		PlayerId:    1,
+		HostName:    "Ведущий",
+		Answer:      "Лион",
+		Result:      siclo.ValidationResult{Result: false, Justification: "Неверно"},
+		ScoreChange: -100,
+		Time:        time.Now(),
+	}
+	var data []byte
+	room.do(func() {
+		room.gameStateInternal.verdicts = []*Verdict{verdict}
+		room.gameUsage.record(1, "Ведущий", siclo.Usage{InputTokens: 10, OutputTokens: 2}, false)
+		room.gameUsage.record(0, "Ведущий", siclo.Usage{}, true)
+
+		data, _ = json.Marshal(room.snapshot())
+	})
+
+	var s roomSnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	restored := newRoom("TEST")
+	t.Cleanup(restored.close)
+	var err error
+	restored.do(func() { err = restored.restore(s) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored.do(func() {
+		found := restored.findVerdict("1_1_1", 1)
+		if found == nil || !found.Time.Equal(verdict.Time) {
+			t.Errorf("verdict = %+v, want %+v", found, verdict)
+			return
+		}
+		got, want := *found, *verdict
+		got.Time, want.Time = time.Time{}, time.Time{}
+		if got != want {
+			t.Errorf("verdict = %+v, want %+v", got, want)
+		}
+	})
+	usage := restored.gameUsage
+	if usage.Total != (UsageStats{Calls: 2, CachedCalls: 1, InputTokens: 10, OutputTokens: 2, Cost: usage.Total.Cost}) {
+		t.Errorf("total = %+v", usage.Total)
+	}
+	if stats := usage.ByPlayer[1]; stats == nil || stats.Calls != 1 || stats.InputTokens != 10 {
+		t.Errorf("player 1 = %+v", stats)
+	}
+	if stats := usage.ByCharacter["Ведущий"]; stats == nil || stats.Calls != 2 {
+		t.Errorf("Ведущий = %+v", stats)
+	}
+}
+
+func TestRestoreRejectsPlayerWithoutRole(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	room := newRoom("TEST")
+	t.Cleanup(room.close)
+	var s roomSnapshot
+	if err := json.Unmarshal([]byte(`{"code":"TEST","players":[{"id":1,"name":"A","score":0}]}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	room.do(func() { err = room.restore(s) })
+	if err == nil {
+		t.Error("snapshot without a role restored")
+	}
+}
+
+// TestSnapshotIsStable проверяет, что снимок без изменений в игре
+// не переписывается: его байты от раза к разу совпадают.
+func TestSnapshotIsStable(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	room := newRoom("TEST")
+	t.Cleanup(room.close)
+	room.do(func() {
+		for i := range 8 {
+			player := &Player{Player: engine.Player{Name: fmt.Sprintf("Игрок %d", i+1), Role: engine.RolePlayer}}
+			if err := room.addPlayer(player); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		first, _ := json.Marshal(room.snapshot())
+		for range 20 {
+			if data, _ := json.Marshal(room.snapshot()); !bytes.Equal(data, first) {
+				t.Errorf("snapshot changed without a change in the game:\n%s\n%s", first, data)
+				return
+			}
+		}
+	})
+}
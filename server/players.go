@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sigo-server/engine"
+)
+
+// Номера игрокам выдает сервер, по порядку входа и общие для всех ролей.
+// Роль (игрок, NPC, ведущий, зритель) хранится у игрока явно и от номера
+// не зависит.
+
+// addPlayer выдает игроку следующий номер и сажает его за стол. Номера не
+// переиспользуются и после сброса игры, иначе токен ушедшего игрока достался
+// бы новому. Зрители в игру движка не входят, поэтому приходят в любой фазе.
+// Вызывается из игрового цикла.
+func (room *Room) addPlayer(player *Player) error {
+	player.ID = room.gameState.nextId
+	if player.Role != engine.RoleSpectator {
+		if err := room.game.Join(&player.Player); err != nil {
+			return err
+		}
+	}
+	room.gameState.nextId++
+	room.gameState.players[player.ID] = player
+	return nil
+}
+
+// host - ведущий комнаты или nil, если его еще не выбрали.
+func (room *Room) host() *Player {
+	for _, p := range room.gameState.players {
+		if p.Role == engine.RoleHost {
+			return p
+		}
+	}
+	return nil
+}
+
+// numberOfAllPlayers - сколько за столом игроков, которые разыгрывают
+// вопросы: живых и NPC.
+func (room *Room) numberOfAllPlayers() int {
+	count := 0
+	for _, p := range room.gameState.players {
+		if p.Contestant() {
+			count++
+		}
+	}
+	return count
+}
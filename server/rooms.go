@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sigo-server/engine"
+)
+
+// Буквы для кодов комнат: без I и O, чтобы не путать с 1 и 0
+const roomCodeLetters = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+const roomCodeLength = 4
+
+// Брошенные комнаты (без клиентов и без запросов дольше roomTTL) удаляются
+// вместе с файлами
+const roomTTL = 12 * time.Hour
+
+// Room - отдельный стол со своей игрой, пакетом, игроками, клиентами и таймерами.
+// Файлы комнаты лежат в rooms/<код>/package и rooms/<код>/players,
+// снимок игры - в rooms/<код>/game.json, журнал - в rooms/<код>/events.jsonl.
+type Room struct {
+	code              string
+	dir               string
+	created           time.Time
+	game              *engine.Game
+	commands          chan func()
+	gameState         *GameState
+	gameStateInternal *GameStateInternal
+	gameUsage         *GameUsage
+	events            *EventLog
+	saved             []byte // последний записанный снимок
+	// active - время последнего запроса к комнате или отключения клиента,
+	// UnixNano. По нему считается, давно ли комната брошена.
+	active atomic.Int64
+}
+
+type RoomRegistry struct {
+	mu    sync.Mutex
+	rooms map[string]*Room
+}
+
+var rooms = &RoomRegistry{
+	rooms: make(map[string]*Room),
+}
+
+func newRoom(code string) *Room {
+	room := &Room{
+		code:     code,
+		dir:      filepath.Join("rooms", code),
+		created:  time.Now(),
+		game:     engine.NewGame(nil),
+		commands: make(chan func()),
+		gameState: &GameState{
+			players: make(map[int]*Player),
+			prompts: defaultPrompts,
+			nextId:  1,
+			clients: make(map[*client]bool),
+			done:    make(chan struct{}),
+			upgrader: websocket.Upgrader{
+				CheckOrigin: func(r *http.Request) bool {
+					return true
+				},
+			},
+		},
+		gameStateInternal: &GameStateInternal{
+			npcAnswers: make(map[int]string),
+		},
+		gameUsage: newGameUsage(),
+	}
+
+	os.MkdirAll(room.path("package"), 0755)
+	os.MkdirAll(room.path("players"), 0755)
+	room.events = openEventLog(room.path(eventLogFile))
+	room.gameState.events = room.events
+	room.touch()
+
+	go room.loop()
+	return room
+}
+
+// touch отмечает, что комнатой только что пользовались.
+func (room *Room) touch() {
+	room.active.Store(time.Now().UnixNano())
+}
+
+// idle - сколько времени комнатой никто не пользуется.
+func (room *Room) idle() time.Duration {
+	return time.Since(time.Unix(0, room.active.Load()))
+}
+
+// path - путь к папке или файлу внутри комнаты.
+func (room *Room) path(name string) string {
+	return filepath.Join(room.dir, name)
+}
+
+// create заводит комнату с новым свободным кодом.
+func (reg *RoomRegistry) create() *Room {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for {
+		code := newRoomCode()
+		if _, exists := reg.rooms[code]; exists {
+			continue
+		}
+		room := newRoom(code)
+		reg.rooms[code] = room
+		log.Printf("[%s] room created", code)
+		return room
+	}
+}
+
+func (reg *RoomRegistry) get(code string) *Room {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.rooms[strings.ToUpper(code)]
+}
+
+func (reg *RoomRegistry) list() []*Room {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	list := make([]*Room, 0, len(reg.rooms))
+	for _, room := range reg.rooms {
+		list = append(list, room)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].created.Before(list[j].created)
+	})
+	return list
+}
+
+// cleanup удаляет комнаты, к которым давно никто не обращался, никто не
+// подключен и где не идет игра.
+func (reg *RoomRegistry) cleanup() {
+	for _, room := range reg.list() {
+		if room.idle() < roomTTL || !room.abandoned() {
+			continue
+		}
+
+		reg.mu.Lock()
+		delete(reg.rooms, room.code)
+		reg.mu.Unlock()
+
+		room.close()
+		log.Printf("[%s] room removed", room.code)
+	}
+}
+
+func (room *Room) abandoned() bool {
+	var idle bool
+	room.do(func() {
+		phase := room.game.Phase()
+		idle = phase == engine.PhaseJoining || phase == engine.PhaseGameOver
+	})
+
+	room.gameState.mu.RLock()
+	defer room.gameState.mu.RUnlock()
+	return idle && len(room.gameState.clients) == 0
+}
+
+// close останавливает таймеры комнаты, отключает клиентов, закрывает журнал и удаляет ее файлы.
+func (room *Room) close() {
+	room.do(func() {
+		room.gameStateInternal.stopTimers()
+	})
+	close(room.gameState.done)
+	room.gameState.closeClients()
+	room.events.close()
+
+	os.RemoveAll(room.dir)
+}
+
+func newRoomCode() string {
+	code := make([]byte, roomCodeLength)
+	for i := range code {
+		code[i] = roomCodeLetters[rand.Intn(len(roomCodeLetters))]
+	}
+	return string(code)
+}
+
+// inRoom находит комнату по коду из пути /rooms/{code}/... и передает ее обработчику.
+// Форму читаем заранее, чтобы медленный клиент не держал игровой цикл,
+// когда обработчик разбирает ее внутри room.do. POST-запросы пишутся в
+// журнал игры.
+func inRoom(h func(room *Room, w http.ResponseWriter, r *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		room := rooms.get(r.PathValue("code"))
+		if room == nil {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		room.touch()
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Error parsing form", http.StatusBadRequest)
+			return
+		}
+		if r.Method != http.MethodPost {
+			h(room, w, r)
+			return
+		}
+
+		// Команды попадают в журнал игры
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h(room, rec, r)
+		room.events.recordCommand(start, r, rec.status)
+	})
+}
+
+func roomURL(code string, page string) string {
+	return fmt.Sprintf("/rooms/%s/%s", code, page)
+}
+
+// handleRooms создает комнату. Списка комнат нет: в комнату попадают только
+// по коду, который ведущий называет игрокам.
+func handleRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	room := rooms.create()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"code":  room.code,
+		"token": room.hostToken(),
+	})
+}
+
+// handleNewRoom создает комнату для ведущего и открывает ее страницу. Токен
+// ведущего передается во фрагменте адреса, который браузер не шлет серверу.
+func handleNewRoom(w http.ResponseWriter, r *http.Request) {
+	room := rooms.create()
+	http.Redirect(w, r, roomURL(room.code, "joinhost.html")+"#token="+room.hostToken(), http.StatusSeeOther)
+}
+
+// handleEnterRoom отправляет игрока по коду комнаты на ее страницу входа.
+func handleEnterRoom(page string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("code")))
+		if code == "" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, `<form method="get"><input name="code" placeholder="Код комнаты" autofocus> <button>Войти</button></form>`)
+			return
+		}
+
+		if rooms.get(code) == nil {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+
+		query := r.URL.Query()
+		query.Del("code")
+		target := roomURL(code, page)
+		if len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+		http.Redirect(w, r, target, http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goldenpineappleofthesun/siclo"
+
+	"sigo-server/engine"
+)
+
+const testPackageXML = `<?xml version="1.0" encoding="utf-8"?>
+<package name="t">
+<rounds>
+<round name="R1"><themes>
+<theme name="Город"><questions>
+<question price="100"><params><param name="question" type="content"><item>Столица Франции?</item></param></params><right><answer>Париж</answer></right></question>
+</questions></theme>
+</themes></round>
+</rounds>
+</package>`
+
+// testSIQ - пакет из одного вопроса, как его загружает ведущий.
+func testSIQ(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("content.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, testPackageXML)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// startTestServer поднимает сервер со всеми маршрутами в пустой папке.
+// Модель заменена заглушкой, которая засчитывает любой ответ.
+func startTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	sessionKey = []byte("test session key")
+
+	model := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{`{\"result\": true, `, `\"justification\": \"Верно\"}`} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"%s\"}}]}\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(model.Close)
+
+	cfg := siclo.DefaultConfig()
+	cfg.Provider = siclo.ProviderOpenAI
+	cfg.Model = "test"
+	cfg.BaseURL = model.URL
+	cfg.APIKey = "test"
+	siclo.Configure(cfg)
+	t.Cleanup(func() { siclo.Configure(siclo.DefaultConfig()) })
+
+	srv := httptest.NewServer(newMux())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testRoom - комната глазами клиента: адрес и токены ведущего и игроков.
+type testRoom struct {
+	t    *testing.T
+	srv  *httptest.Server
+	code string
+	host string
+	room *Room
+
+	mu     sync.Mutex
+	tokens map[int]string
+}
+
+func newTestRoom(t *testing.T, srv *httptest.Server) *testRoom {
+	resp, err := http.Post(srv.URL+"/rooms", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var created struct {
+		Code  string `json:"code"`
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	room := rooms.get(created.Code)
+	t.Cleanup(func() {
+		// комнату мог уже удалить сам тест
+		rooms.mu.Lock()
+		registered := rooms.rooms[room.code] == room
+		delete(rooms.rooms, room.code)
+		rooms.mu.Unlock()
+		if registered {
+			room.close()
+		}
+	})
+	return &testRoom{t: t, srv: srv, code: created.Code, host: created.Token, room: room, tokens: make(map[int]string)}
+}
+
+// post отправляет команду комнаты от игрока id (0 - без токена).
+func (tr *testRoom) post(path string, id int, form url.Values) (int, string) {
+	tr.mu.Lock()
+	token := tr.tokens[id]
+	tr.mu.Unlock()
+	return tr.postAs(path, token, form)
+}
+
+// hostPost отправляет команду ведущего.
+func (tr *testRoom) hostPost(path string, form url.Values) (int, string) {
+	return tr.postAs(path, tr.host, form)
+}
+
+func (tr *testRoom) postAs(path string, token string, form url.Values) (int, string) {
+	req, err := http.NewRequest(http.MethodPost, tr.srv.URL+roomURL(tr.code, path), strings.NewReader(form.Encode()))
+	if err != nil {
+		tr.t.Error(err)
+		return 0, ""
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tr.t.Error(err)
+		return 0, ""
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+// get запрашивает страницу комнаты с токеном token.
+func (tr *testRoom) get(path string, token string) (int, string) {
+	req, err := http.NewRequest(http.MethodGet, tr.srv.URL+roomURL(tr.code, path), nil)
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func (tr *testRoom) upload() {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "t.siq")
+	fw.Write(testSIQ(tr.t))
+	mw.Close()
+
+	req, err := http.NewRequest(http.MethodPost, tr.srv.URL+roomURL(tr.code, "upload"), &body)
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+tr.host)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		tr.t.Fatalf("[%s] upload: %s", tr.code, resp.Status)
+	}
+}
+
+func (tr *testRoom) join(name string) {
+	status, body := tr.post("join", 0, url.Values{"name": {name}})
+	if status != http.StatusOK {
+		tr.t.Errorf("[%s] join %s: %d %s", tr.code, name, status, body)
+		return
+	}
+	var session struct {
+		ID    int    `json:"id"`
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal([]byte(body), &session); err != nil {
+		tr.t.Error(err)
+		return
+	}
+	tr.mu.Lock()
+	tr.tokens[session.ID] = session.Token
+	tr.mu.Unlock()
+}
+
+// each выполняет f за всех игроков одновременно и возвращает ответы по номерам.
+func (tr *testRoom) each(f func(id int) int) map[int]int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	statuses := make(map[int]int)
+	for id := range tr.tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := f(id)
+			mu.Lock()
+			statuses[id] = status
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return statuses
+}
+
+// game читает состояние игры через игровой цикл комнаты.
+func (tr *testRoom) game(f func(g *engine.Game)) {
+	tr.room.do(func() { f(tr.room.game) })
+}
+
+func (tr *testRoom) waitFor(what string, ok func(g *engine.Game) bool) bool {
+	deadline := time.Now().Add(buzzWindow + 5*time.Second)
+	for time.Now().Before(deadline) {
+		var done bool
+		tr.game(func(g *engine.Game) { done = ok(g) })
+		if done {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	tr.t.Errorf("[%s] timed out waiting for %s", tr.code, what)
+	return false
+}
+
+func (tr *testRoom) waitPhase(phase engine.Phase) bool {
+	return tr.waitFor(string(phase), func(g *engine.Game) bool { return g.Phase() == phase })
+}
+
+// play проводит в комнате один вопрос: игроки входят, подтверждают старт,
+// читают вопрос, жмут на кнопку и отвечают одновременно.
+func (tr *testRoom) play(players int) {
+	tr.upload()
+
+	var wg sync.WaitGroup
+	for i := range players {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tr.join(fmt.Sprintf("%s игрок %d", tr.code, i+1))
+		}()
+	}
+	wg.Wait()
+	if len(tr.tokens) != players {
+		tr.t.Errorf("[%s] %d players joined, want %d", tr.code, len(tr.tokens), players)
+		return
+	}
+
+	if status, body := tr.hostPost("start", nil); status != http.StatusOK {
+		tr.t.Errorf("[%s] start: %d %s", tr.code, status, body)
+		return
+	}
+	tr.each(func(id int) int {
+		status, _ := tr.post("startacknowledge", id, nil)
+		return status
+	})
+	if !tr.waitPhase(engine.PhaseSelectQuestion) {
+		return
+	}
+
+	var current int
+	tr.game(func(g *engine.Game) { current = g.CurrentPlayer() })
+	form := url.Values{"round": {"1"}, "theme": {"1"}, "question": {"1"}}
+	if status, body := tr.post("selectquestion", current, form); status != http.StatusOK {
+		tr.t.Errorf("[%s] select by %d: %d %s", tr.code, current, status, body)
+		return
+	}
+
+	tr.each(func(id int) int {
+		status, _ := tr.post("questionbeenshown", id, nil)
+		return status
+	})
+	if !tr.waitFor("buzzer", (*engine.Game).Opened) {
+		return
+	}
+
+	for id, status := range tr.each(func(id int) int {
+		status, _ := tr.post("requestanswer", id, nil)
+		return status
+	}) {
+		if status != http.StatusOK {
+			tr.t.Errorf("[%s] buzz by %d: %d", tr.code, id, status)
+		}
+	}
+	if !tr.waitPhase(engine.PhaseWaitAnswer) {
+		return
+	}
+
+	// отвечают все, но принят должен быть только ответ того, кому дали слово
+	var answering int
+	tr.game(func(g *engine.Game) { answering = g.Answering() })
+	accepted := 0
+	for id, status := range tr.each(func(id int) int {
+		status, _ := tr.post("answer", id, url.Values{"id-quest": {"1_1_1"}, "text": {"Париж"}})
+		return status
+	}) {
+		if status == http.StatusOK {
+			accepted++
+			if id != answering {
+				tr.t.Errorf("[%s] answer of %d accepted, %d answers", tr.code, id, answering)
+			}
+		}
+	}
+	if accepted != 1 {
+		tr.t.Errorf("[%s] %d answers accepted, want 1", tr.code, accepted)
+	}
+
+	if !tr.waitPhase(engine.PhaseShowAnswer) {
+		return
+	}
+	tr.game(func(g *engine.Game) {
+		if score := g.Player(answering).Score; score != 100 {
+			tr.t.Errorf("[%s] player %d score = %d, want 100", tr.code, answering, score)
+		}
+	})
+}
+
+// TestConcurrentRooms играет в нескольких комнатах одновременно, пока
+// другие клиенты читают состояние комнат. Гонки ловит
+// go test -race.
+func TestConcurrentRooms(t *testing.T) {
+	srv := startTestServer(t)
+
+	const roomCount, players = 4, 3
+	testRooms := make([]*testRoom, roomCount)
+	for i := range testRooms {
+		testRooms[i] = newTestRoom(t, srv)
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for _, tr := range testRooms {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for _, path := range []string{roomURL(tr.code, "state"), roomURL(tr.code, "scores"), roomURL(tr.code, "data")} {
+					if resp, err := http.Get(srv.URL + path); err == nil {
+						io.Copy(io.Discard, resp.Body)
+						resp.Body.Close()
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for _, tr := range testRooms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tr.play(players)
+		}()
+	}
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	// у каждой комнаты свои игроки и номера
+	for _, tr := range testRooms {
+		tr.game(func(g *engine.Game) {
+			for id := 1; id <= players; id++ {
+				if p := g.Player(id); p == nil || !strings.HasPrefix(p.Name, tr.code) {
+					t.Errorf("[%s] player %d = %+v", tr.code, id, p)
+				}
+			}
+		})
+	}
+}
+
+// TestEventLogNeedsHost проверяет, что журнал отдается только ведущему и
+// начинается заново после сброса игры.
+func TestEventLogNeedsHost(t *testing.T) {
+	srv := startTestServer(t)
+	tr := newTestRoom(t, srv)
+	tr.upload()
+	tr.join("Игрок")
+
+	for name, token := range map[string]string{"no": "", "player": tr.tokens[1], "other room": newTestRoom(t, srv).host} {
+		if status, _ := tr.get("events", token); status != http.StatusUnauthorized {
+			t.Errorf("events with %s token: %d, want 401", name, status)
+		}
+	}
+
+	status, body := tr.get("events", tr.host)
+	if status != http.StatusOK || !strings.Contains(body, `"name":"join"`) {
+		t.Fatalf("events: %d %s", status, body)
+	}
+
+	if status, body := tr.hostPost("reset", nil); status != http.StatusOK {
+		t.Fatalf("reset: %d %s", status, body)
+	}
+	status, body = tr.get("events", tr.host)
+	if status != http.StatusOK || strings.Contains(body, `"name":"join"`) || !strings.Contains(body, `"name":"reset"`) {
+		t.Errorf("events after reset: %d %s", status, body)
+	}
+}
+
+// TestHostCommandsNeedHost проверяет, что команды ведущего не проходят без
+// его токена и с токеном игрока.
+func TestHostCommandsNeedHost(t *testing.T) {
+	srv := startTestServer(t)
+	tr := newTestRoom(t, srv)
+	tr.upload()
+	tr.join("Игрок")
+
+	for _, path := range []string{"upload", "joinnpc", "joinshowman", "start", "reset"} {
+		for name, token := range map[string]string{"no": "", "player": tr.tokens[1], "other room": newTestRoom(t, srv).host} {
+			if status, _ := tr.postAs(path, token, nil); status != http.StatusUnauthorized {
+				t.Errorf("%s with %s token: %d, want 401", path, name, status)
+			}
+		}
+	}
+
+	var phase engine.Phase
+	tr.game(func(g *engine.Game) { phase = g.Phase() })
+	if phase != engine.PhaseJoining {
+		t.Fatalf("phase %s after rejected commands", phase)
+	}
+	if status, body := tr.hostPost("start", nil); status != http.StatusOK {
+		t.Errorf("start by host: %d %s", status, body)
+	}
+}
+
+func TestRoomListIsNotServed(t *testing.T) {
+	srv := startTestServer(t)
+	newTestRoom(t, srv)
+
+	resp, err := http.Get(srv.URL + "/rooms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /rooms: %s, want 405", resp.Status)
+	}
+}
+
+// TestCleanupCountsFromLastActivity проверяет, что старую, но недавно
+// игравшую комнату не удаляют, а брошенную удаляют.
+func TestCleanupCountsFromLastActivity(t *testing.T) {
+	srv := startTestServer(t)
+	tr := newTestRoom(t, srv)
+	tr.room.created = time.Now().Add(-2 * roomTTL)
+
+	tr.get("state", "")
+	rooms.cleanup()
+	if rooms.get(tr.code) == nil {
+		t.Fatal("room used just now was removed")
+	}
+
+	tr.room.active.Store(time.Now().Add(-roomTTL - time.Minute).UnixNano())
+	rooms.cleanup()
+	if rooms.get(tr.code) != nil {
+		t.Error("abandoned room was kept")
+	}
+}
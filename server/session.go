@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Игрок получает токен сессии при входе в комнату (POST /join) и подписывает
+// им каждую команду: заголовок Authorization: Bearer <токен>, а WebSocket,
+// которому браузер заголовки не передает, - параметр token. Номер игрока
+// сервер берет из токена, номерам из формы не верим.
+//
+// Токен - "<номер>.<подпись>", подпись - HMAC-SHA256 от кода комнаты, времени
+// ее создания и номера игрока. Токен другой комнаты, в том числе удаленной
+// комнаты с тем же кодом, не подходит.
+//
+// Ведущий получает токен комнаты при ее создании. Токен ведущего -
+// "host.<подпись>", подпись - HMAC-SHA256 от кода комнаты, времени создания и
+// слова host, так что игроку он не достанется, а ведущий не сойдет за игрока.
+
+const sessionKeyFile = "session.key"
+
+var sessionKey []byte
+
+// loadSessionKey берет ключ подписи из SIGO_SESSION_SECRET, а без нее - из
+// файла (SIGO_SESSION_KEY_PATH, по умолчанию session.key). Если файла нет,
+// ключ генерируется и сохраняется, чтобы токены пережили перезапуск.
+func loadSessionKey() error {
+	if secret := os.Getenv("SIGO_SESSION_SECRET"); secret != "" {
+		sessionKey = []byte(secret)
+		return nil
+	}
+
+	path := os.Getenv("SIGO_SESSION_KEY_PATH")
+	if path == "" {
+		path = sessionKeyFile
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		sessionKey = []byte(strings.TrimSpace(string(data)))
+		return nil
+	}
+
+	// Без ключа сервер не может выдавать токены, поэтому новый ключ
+	// используется, даже если сохранить его не вышло
+	key := make([]byte, 32)
+	rand.Read(key)
+	sessionKey = []byte(hex.EncodeToString(key))
+	if err := os.WriteFile(path, append(sessionKey, '\n'), 0600); err != nil {
+		return fmt.Errorf("session key is not saved, tokens will not survive restart: %w", err)
+	}
+	return nil
+}
+
+const hostSubject = "host"
+
+// signature подписывает владельца токена: номер игрока или hostSubject.
+func (room *Room) signature(subject string) []byte {
+	mac := hmac.New(sha256.New, sessionKey)
+	fmt.Fprintf(mac, "%s.%d.%s", room.code, room.created.UnixNano(), subject)
+	return mac.Sum(nil)
+}
+
+func (room *Room) sessionSignature(id int) []byte {
+	return room.signature(strconv.Itoa(id))
+}
+
+// sessionToken выдает токен игроку id этой комнаты.
+func (room *Room) sessionToken(id int) string {
+	return strconv.Itoa(id) + "." + base64.RawURLEncoding.EncodeToString(room.sessionSignature(id))
+}
+
+// sessionPlayer проверяет токен и возвращает номер игрока из него.
+func (room *Room) sessionPlayer(token string) (int, bool) {
+	idStr, sig, found := strings.Cut(token, ".")
+	if !found {
+		return 0, false
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, room.sessionSignature(id)) {
+		return 0, false
+	}
+	return id, true
+}
+
+// hostToken выдает токен ведущему этой комнаты.
+func (room *Room) hostToken() string {
+	return hostSubject + "." + base64.RawURLEncoding.EncodeToString(room.signature(hostSubject))
+}
+
+// isHost проверяет токен ведущего.
+func (room *Room) isHost(token string) bool {
+	sig, found := strings.CutPrefix(token, hostSubject+".")
+	if !found {
+		return false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	return err == nil && hmac.Equal(mac, room.signature(hostSubject))
+}
+
+// requestToken достает токен из заголовка Authorization или параметра token.
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		token, found := strings.CutPrefix(auth, "Bearer ")
+		if found {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return r.FormValue("token")
+}
+
+// asPlayer пропускает к обработчику только запросы с токеном этой комнаты и
+// передает ему номер игрока из токена.
+func asPlayer(h func(room *Room, w http.ResponseWriter, r *http.Request, player int)) func(room *Room, w http.ResponseWriter, r *http.Request) {
+	return func(room *Room, w http.ResponseWriter, r *http.Request) {
+		player, ok := room.sessionPlayer(requestToken(r))
+		if !ok {
+			http.Error(w, "Invalid or missing session token", http.StatusUnauthorized)
+			return
+		}
+
+		// В журнал команда попадает с игроком из токена, а не с самим токеном
+		r.Form.Del("token")
+		r.Form.Set("player", strconv.Itoa(player))
+
+		h(room, w, r, player)
+	}
+}
+
+// asHost пропускает к обработчику только запросы с токеном ведущего комнаты.
+func asHost(h func(room *Room, w http.ResponseWriter, r *http.Request)) func(room *Room, w http.ResponseWriter, r *http.Request) {
+	return func(room *Room, w http.ResponseWriter, r *http.Request) {
+		if !room.isHost(requestToken(r)) {
+			http.Error(w, "Invalid or missing host token", http.StatusUnauthorized)
+			return
+		}
+
+		// Токен ведущего в журнал не пишем
+		r.Form.Del("token")
+
+		h(room, w, r)
+	}
+}
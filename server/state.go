@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"sigo-server/engine"
+)
+
+// StateMessage - полное состояние игры. Его получает только что
+// подключившийся клиент, чтобы нарисовать экран с того места, где идет игра.
+type StateMessage struct {
+	Type          string          `json:"type"` // всегда "state"
+	State         engine.Phase    `json:"state"`
+	CurrentPlayer int             `json:"currentPlayer"`
+	Round         int             `json:"round"`
+	Table         string          `json:"table,omitempty"`
+	Question      *QuestionState  `json:"question,omitempty"`
+	Players       []engine.Player `json:"players"`
+	Timer         *TimerState     `json:"timer,omitempty"`
+}
+
+// QuestionState - выбранный вопрос и кто на него отвечает.
+type QuestionState struct {
+	ID    string `json:"id"`
+	Price int    `json:"price"`
+	// Opened - все дочитали вопрос и кнопка "ответить" доступна с CanAnswerTimestamp
+	Opened             bool  `json:"opened"`
+	CanAnswerTimestamp int64 `json:"canAnswerTimestamp,omitempty"`
+	// Buzzing - кто-то нажал "ответить", сервер выбирает отвечающего
+	Buzzing    bool  `json:"buzzing"`
+	Tried      []int `json:"tried"`
+	Answering  int   `json:"answering"`
+	Clarifying bool  `json:"clarifying"`
+}
+
+// TimerState - серверный таймер текущей фазы: start-ack, buzz, clarify
+// или show-answer.
+type TimerState struct {
+	Name        string `json:"name"`
+	RemainingMs int64  `json:"remainingMs"`
+}
+
+// stateMessage собирает состояние для нового клиента. Вызывается из игрового цикла.
+func (room *Room) stateMessage() StateMessage {
+	game := room.game
+	msg := StateMessage{
+		Type:          "state",
+		State:         game.Phase(),
+		CurrentPlayer: game.CurrentPlayer(),
+		Round:         game.Round(),
+		Players:       make([]engine.Player, 0, len(room.gameState.players)),
+	}
+
+	for _, p := range room.gameState.players {
+		msg.Players = append(msg.Players, p.Player)
+	}
+	sort.Slice(msg.Players, func(i, j int) bool {
+		return msg.Players[i].ID < msg.Players[j].ID
+	})
+
+	if game.Round() > 0 && room.gameState.packageJson != nil {
+		msg.Table, _, _ = room.getQuestionsTable(game.Round()-1, true)
+	}
+
+	phase := game.Phase()
+	if phase == engine.PhaseQuestion || phase == engine.PhaseWaitAnswer || phase == engine.PhaseShowAnswer {
+		msg.Question = &QuestionState{
+			ID:         game.Question(),
+			Price:      room.getScore(game.Question()),
+			Opened:     game.Opened(),
+			Buzzing:    game.Buzzing(),
+			Tried:      game.Tried(),
+			Answering:  game.Answering(),
+			Clarifying: game.Clarifying(),
+		}
+		if game.Opened() {
+			msg.Question.CanAnswerTimestamp = room.gameStateInternal.canAnswerTimestamp
+		}
+	}
+
+	var timer string
+	switch {
+	case phase == engine.PhaseStartAck:
+		timer = "start-ack"
+	case phase == engine.PhaseQuestion && game.Buzzing():
+		timer = "buzz"
+	case phase == engine.PhaseWaitAnswer && room.gameStateInternal.clarifyVerdict != nil:
+		timer = "clarify"
+	case phase == engine.PhaseShowAnswer:
+		timer = "show-answer"
+	}
+	if remaining := time.Until(room.gameStateInternal.deadline); timer != "" && remaining > 0 {
+		msg.Timer = &TimerState{Name: timer, RemainingMs: remaining.Milliseconds()}
+	}
+
+	return msg
+}
@@ -0,0 +1,74 @@
+package siclo
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	minBuzzDelay = 700 * time.Millisecond
+	maxBuzzDelay = 4 * time.Second
+)
+
+type answerPrompt struct {
+	Name     string
+	Persona  string
+	Theme    string
+	Price    int
+	Question string
+}
+
+// GenerateAnswer решает, нажмет ли персонаж на кнопку, и что он ответит.
+// Ответ пишется голосом персонажа (player_prompt) и ограничен тем, что
+// персонаж может знать.
+func GenerateAnswer(prompts *Prompts, character Character, question, theme string, price int) (*NPCAnswer, error) {
+	prompt, err := prompts.render("answer", answerPrompt{
+		Name:     character.Name,
+		Persona:  character.Persona(PersonaPlayer),
+		Theme:    theme,
+		Price:    price,
+		Question: question,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	text, usage, err := complete(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var answer NPCAnswer
+	if err := decodeJSON(text, &answer); err != nil {
+		return nil, err
+	}
+	answer.Usage = usage
+
+	answer.Answer = strings.TrimSpace(answer.Answer)
+	answer.Confidence = clamp(answer.Confidence, 0, 1)
+	if answer.Answer == "" {
+		answer.Buzz = false
+	}
+	answer.Delay = buzzDelay(answer.Confidence)
+
+	return &answer, nil
+}
+
+// buzzDelay - чем увереннее персонаж, тем быстрее он жмет на кнопку.
+func buzzDelay(confidence float64) time.Duration {
+	spread := float64(maxBuzzDelay - minBuzzDelay)
+	delay := minBuzzDelay + time.Duration((1-confidence)*spread*0.8)
+	jitter := time.Duration(rand.Float64() * spread * 0.2)
+	return delay + jitter
+}
+
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
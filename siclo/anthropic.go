@@ -0,0 +1,109 @@
+package siclo
+
+import (
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+type anthropicProvider struct {
+	cfg Config
+}
+
+func (p anthropicProvider) Complete(prompt string) (string, Usage, error) {
+	client, params, err := p.newRequest(prompt)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	ctx, cancel := p.cfg.requestContext()
+	defer cancel()
+
+	message, err := client.Messages.New(ctx, params)
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("model request failed: %w", err)
+	}
+
+	return messageText(message)
+}
+
+func (p anthropicProvider) CompleteStream(prompt string, onText StreamFunc) (string, Usage, error) {
+	client, params, err := p.newRequest(prompt)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	ctx, cancel := p.cfg.requestContext()
+	defer cancel()
+
+	stream := client.Messages.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	message := anthropic.Message{}
+
+	for stream.Next() {
+		event := stream.Current()
+		if err := message.Accumulate(event); err != nil {
+			return "", Usage{}, fmt.Errorf("model stream failed: %w", err)
+		}
+
+		delta, ok := event.AsAny().(anthropic.ContentBlockDeltaEvent)
+		if !ok {
+			continue
+		}
+		if text, ok := delta.Delta.AsAny().(anthropic.TextDelta); ok {
+			onText(text.Text)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return "", Usage{}, fmt.Errorf("model request failed: %w", err)
+	}
+
+	return messageText(&message)
+}
+
+// newRequest собирает клиента и параметры запроса по Config.
+func (p anthropicProvider) newRequest(prompt string) (anthropic.Client, anthropic.MessageNewParams, error) {
+	cfg := p.cfg
+
+	key, err := cfg.apiKey()
+	if err != nil {
+		return anthropic.Client{}, anthropic.MessageNewParams{}, err
+	}
+
+	opts := []option.RequestOption{option.WithAPIKey(key)}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+	}
+	client := anthropic.NewClient(opts...)
+
+	params := anthropic.MessageNewParams{
+		MaxTokens: cfg.MaxTokens,
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
+		},
+		Model: anthropic.Model(cfg.Model),
+	}
+	if cfg.Temperature >= 0 {
+		params.Temperature = anthropic.Float(cfg.Temperature)
+	}
+	if cfg.System != "" {
+		params.System = []anthropic.TextBlockParam{{Text: cfg.System}}
+	}
+
+	return client, params, nil
+}
+
+func messageText(message *anthropic.Message) (string, Usage, error) {
+	usage := Usage{
+		InputTokens:  message.Usage.InputTokens,
+		OutputTokens: message.Usage.OutputTokens,
+	}
+
+	if len(message.Content) == 0 {
+		return "", usage, fmt.Errorf("model returned empty response")
+	}
+
+	return message.Content[0].Text, usage, nil
+}
@@ -0,0 +1,225 @@
+package siclo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// anthropicRequest - поля запроса Messages API, которые задает провайдер.
+type anthropicRequest struct {
+	Model       string   `json:"model"`
+	MaxTokens   int64    `json:"max_tokens"`
+	Temperature *float64 `json:"temperature"`
+	Stream      bool     `json:"stream"`
+	System      []struct {
+		Text string `json:"text"`
+	} `json:"system"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"messages"`
+}
+
+// anthropicServer поднимает заглушку Messages API: проверяет запрос через
+// check и отвечает handler.
+func anthropicServer(t *testing.T, check func(r *http.Request, req anthropicRequest), handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/messages" {
+			t.Errorf("request %s %s, want POST /v1/messages", r.Method, r.URL.Path)
+		}
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("request body is not json: %v", err)
+		}
+		if check != nil {
+			check(r, req)
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func anthropicTestConfig(baseURL string) Config {
+	cfg := DefaultConfig()
+	cfg.BaseURL = baseURL
+	cfg.APIKey = "test-key"
+	cfg.System = "system prompt"
+	cfg.Temperature = 0.5
+	return cfg
+}
+
+func TestAnthropicComplete(t *testing.T) {
+	srv := anthropicServer(t, func(r *http.Request, req anthropicRequest) {
+		if got := r.Header.Get("X-Api-Key"); got != "test-key" {
+			t.Errorf("X-Api-Key = %q", got)
+		}
+		if r.Header.Get("Anthropic-Version") == "" {
+			t.Error("Anthropic-Version header is missing")
+		}
+		if req.Model != defaultModels[ProviderAnthropic] || req.MaxTokens != 1024 || req.Stream {
+			t.Errorf("request = %+v", req)
+		}
+		if req.Temperature == nil || *req.Temperature != 0.5 {
+			t.Errorf("temperature = %v, want 0.5", req.Temperature)
+		}
+		if len(req.System) != 1 || req.System[0].Text != "system prompt" {
+			t.Errorf("system = %+v", req.System)
+		}
+		if len(req.Messages) != 1 || req.Messages[0].Role != "user" ||
+			len(req.Messages[0].Content) != 1 || req.Messages[0].Content[0].Text != "prompt" {
+			t.Errorf("messages = %+v", req.Messages)
+		}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"m",
+			"content":[{"type":"text","text":"ответ"}],
+			"stop_reason":"end_turn","usage":{"input_tokens":12,"output_tokens":3}}`)
+	})
+
+	provider, err := newProvider(anthropicTestConfig(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, usage, err := provider.Complete("prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "ответ" || usage != (Usage{InputTokens: 12, OutputTokens: 3}) {
+		t.Errorf("Complete = %q, %+v", text, usage)
+	}
+}
+
+func TestAnthropicErrors(t *testing.T) {
+	// Статусы, на которых SDK не повторяет запрос, чтобы тест не ждал
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"status", http.StatusBadRequest, `{"type":"error","error":{"type":"invalid_request_error","message":"bad prompt"}}`, "400"},
+		{"malformed json", http.StatusOK, `{"content":[`, "model request failed"},
+		{"no content", http.StatusOK, `{"type":"message","content":[],"usage":{"input_tokens":1,"output_tokens":0}}`, "empty response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := anthropicServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+			_, _, err := (anthropicProvider{cfg: anthropicTestConfig(srv.URL)}).Complete("prompt")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeAnthropicEvents отдает события потока Messages API. Имя события
+// берется из начала JSON, чтобы можно было отдать и битое событие.
+func writeAnthropicEvents(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, event := range events {
+		_, name, _ := strings.Cut(event, `{"type":"`)
+		name, _, _ = strings.Cut(name, `"`)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, event)
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	srv := anthropicServer(t, func(r *http.Request, req anthropicRequest) {
+		if !req.Stream {
+			t.Errorf("request = %+v, want stream", req)
+		}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		writeAnthropicEvents(w,
+			`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"m","content":[],"usage":{"input_tokens":7,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"{\"result\": "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"true}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+			`{"type":"message_stop"}`,
+		)
+	})
+
+	var deltas []string
+	text, usage, err := (anthropicProvider{cfg: anthropicTestConfig(srv.URL)}).CompleteStream("prompt", func(s string) {
+		deltas = append(deltas, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != `{"result": true}` || usage != (Usage{InputTokens: 7, OutputTokens: 2}) {
+		t.Errorf("CompleteStream = %q, %+v", text, usage)
+	}
+	if len(deltas) != 2 || deltas[0] != `{"result": ` {
+		t.Errorf("deltas = %q", deltas)
+	}
+}
+
+func TestAnthropicStreamErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		events  []string
+		wantErr string
+	}{
+		{"status", http.StatusUnauthorized, nil, "401"},
+		{"malformed event", http.StatusOK, []string{`{"type":"message_start","message":`}, "model request failed"},
+		{"empty", http.StatusOK, []string{
+			`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"m","content":[],"usage":{"input_tokens":7,"output_tokens":0}}}`,
+			`{"type":"message_stop"}`,
+		}, "empty response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := anthropicServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+				if tt.status != http.StatusOK {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(tt.status)
+					fmt.Fprint(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+					return
+				}
+				writeAnthropicEvents(w, tt.events...)
+			})
+			_, _, err := (anthropicProvider{cfg: anthropicTestConfig(srv.URL)}).CompleteStream("prompt", func(string) {})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnthropicTimeout(t *testing.T) {
+	srv := anthropicServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		// модель зависла: ответа нет, пока клиент не сдастся
+		<-r.Context().Done()
+	})
+	cfg := anthropicTestConfig(srv.URL)
+	cfg.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	_, _, err := (anthropicProvider{cfg: cfg}).Complete("prompt")
+	if err == nil {
+		t.Fatal("Complete returned without error")
+	}
+	_, _, err = (anthropicProvider{cfg: cfg}).CompleteStream("prompt", func(string) {})
+	if err == nil {
+		t.Fatal("CompleteStream returned without error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("requests took %v with a %v timeout", elapsed, cfg.Timeout)
+	}
+}
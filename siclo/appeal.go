@@ -0,0 +1,52 @@
+package siclo
+
+type appealPrompt struct {
+	validatePrompt
+	Result        bool
+	Justification string
+}
+
+// ReviewAnswer проверяет ответ повторно, когда игрок оспаривает вердикт first.
+// Модель смотрит на ответ строже и знает прежнее решение; если решение
+// изменилось, новый вердикт попадает в кеш вместо старого.
+func ReviewAnswer(prompts *Prompts, characterName, characterPrompt, question, givenAnswer, rightAnswer string, first ValidationResult) (*ValidationResult, error) {
+	if DetectInjection(givenAnswer) {
+		return &ValidationResult{
+			Result:    first.Result,
+			Injection: true,
+		}, nil
+	}
+
+	prompt, err := prompts.render("appeal", appealPrompt{
+		validatePrompt: validatePrompt{
+			Name:     characterName,
+			Persona:  characterPrompt,
+			Question: question,
+			Expected: rightAnswer,
+			Given:    givenAnswer,
+		},
+		Result:        first.Result,
+		Justification: first.Justification,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	text, usage, err := complete(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ValidationResult
+	if err := decodeJSON(text, &result); err != nil {
+		return nil, err
+	}
+	result.Usage = usage
+	result.Justification, result.Redacted = RedactLeaks(result.Justification, rightAnswer)
+
+	if verdictCache != nil && result.Result != first.Result {
+		_ = verdictCache.Put(prompts, characterName, characterPrompt, question, givenAnswer, rightAnswer, result)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,90 @@
+package siclo
+
+type batchPrompt struct {
+	Name     string
+	Persona  string
+	Question string
+	Expected string
+	Answers  map[int]string
+}
+
+// BatchResult - вердикты по ответам нескольких игроков на один вопрос.
+type BatchResult struct {
+	Results map[int]*ValidationResult
+	// Usage - токены всего запроса; у отдельных вердиктов она нулевая.
+	Usage Usage
+}
+
+// ValidateBatch проверяет ответы нескольких игроков на один вопрос одним
+// запросом к модели. answers - ответы по номерам игроков, вердикты
+// возвращаются по тем же номерам. Ответы из кеша и попытки управлять
+// моделью в запрос не попадают.
+func ValidateBatch(prompts *Prompts, characterName, characterPrompt, question string, answers map[int]string, rightAnswer string) (*BatchResult, error) {
+	batch := &BatchResult{Results: make(map[int]*ValidationResult, len(answers))}
+	pending := make(map[int]string)
+
+	for id, given := range answers {
+		if DetectInjection(given) {
+			batch.Results[id] = &ValidationResult{
+				Result:    MatchLocally(given, rightAnswer),
+				Injection: true,
+			}
+			continue
+		}
+		if verdictCache != nil {
+			if result, ok := verdictCache.Get(prompts, characterName, characterPrompt, question, given, rightAnswer); ok {
+				result.Cached = true
+				batch.Results[id] = result
+				continue
+			}
+		}
+		pending[id] = given
+	}
+
+	if len(pending) == 0 {
+		return batch, nil
+	}
+
+	prompt, err := prompts.render("validate_batch", batchPrompt{
+		Name:     characterName,
+		Persona:  characterPrompt,
+		Question: question,
+		Expected: rightAnswer,
+		Answers:  pending,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	text, usage, err := complete(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var reply struct {
+		Results map[int]ValidationResult `json:"results"`
+	}
+	if err := decodeJSON(text, &reply); err != nil {
+		return nil, err
+	}
+	batch.Usage = usage
+
+	for id, given := range pending {
+		result, ok := reply.Results[id]
+		if !ok {
+			// модель пропустила игрока - решаем без нее
+			batch.Results[id] = &ValidationResult{Result: MatchLocally(given, rightAnswer)}
+			continue
+		}
+
+		result.Clarify = result.Clarify && !result.Result
+		result.Justification, result.Redacted = RedactLeaks(result.Justification, rightAnswer)
+		batch.Results[id] = &result
+
+		if verdictCache != nil {
+			_ = verdictCache.Put(prompts, characterName, characterPrompt, question, given, rightAnswer, result)
+		}
+	}
+
+	return batch, nil
+}
@@ -0,0 +1,257 @@
+package siclo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const DefaultCacheTTL = 30 * 24 * time.Hour
+
+// CacheEntry - сохраненный вердикт ведущего на конкретный ответ.
+type CacheEntry struct {
+	Question  string           `json:"question"`
+	Expected  string           `json:"expected"`
+	Given     string           `json:"given"`
+	Persona   string           `json:"persona"`
+	Result    ValidationResult `json:"result"`
+	CreatedAt time.Time        `json:"created_at"`
+	Hits      int              `json:"hits"`
+}
+
+// Cache хранит вердикты в памяти и сохраняет их в JSON файл после каждого
+// изменения. Ключ строится из нормализованных вопроса, ответа с карточки,
+// ответа игрока и персонажа ведущего, а также из набора промптов и модели:
+// вердикт на другом языке, с шаблонами другого пакета или от другой модели
+// может отличаться.
+type Cache struct {
+	mu      sync.Mutex
+	path    string
+	ttl     time.Duration
+	entries map[string]*CacheEntry
+}
+
+type CacheStats struct {
+	Entries int `json:"entries"`
+	Expired int `json:"expired"`
+	Hits    int `json:"hits"`
+}
+
+var verdictCache *Cache
+
+// UseCache включает кеш вердиктов для ValidateAnswer. nil выключает кеш.
+func UseCache(c *Cache) {
+	verdictCache = c
+}
+
+// OpenCache загружает кеш из файла. Если файла нет, кеш создается пустым.
+// ttl <= 0 означает, что записи не устаревают.
+func OpenCache(path string, ttl time.Duration) (*Cache, error) {
+	c := &Cache{
+		path:    path,
+		ttl:     ttl,
+		entries: make(map[string]*CacheEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// папку проверяем сразу, а не при первой записи
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cache dir: %w", err)
+		}
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse cache file: %w", err)
+	}
+
+	return c, nil
+}
+
+func (c *Cache) Get(prompts *Prompts, characterName, characterPrompt, question, givenAnswer, rightAnswer string) (*ValidationResult, bool) {
+	key := cacheKey(prompts, characterName+" "+characterPrompt, question, givenAnswer, rightAnswer)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || c.expired(entry) {
+		return nil, false
+	}
+
+	entry.Hits++
+	result := entry.Result
+	// вердикт из кеша бесплатный
+	result.Usage = Usage{}
+	return &result, true
+}
+
+func (c *Cache) Put(prompts *Prompts, characterName, characterPrompt, question, givenAnswer, rightAnswer string, result ValidationResult) error {
+	persona := characterName + " " + characterPrompt
+	key := cacheKey(prompts, persona, question, givenAnswer, rightAnswer)
+
+	// токены тратил исходный запрос, повтору из кеша они не достаются
+	result.Usage = Usage{}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = &CacheEntry{
+		Question:  question,
+		Expected:  rightAnswer,
+		Given:     givenAnswer,
+		Persona:   persona,
+		Result:    result,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := c.save(); err != nil {
+		// Вызывающие не ждут ошибок кеша, поэтому говорим о ней один раз
+		// и дальше держим кеш только в памяти
+		log.Printf("siclo: verdict cache is kept in memory only: %v", err)
+		c.path = ""
+		return err
+	}
+	return nil
+}
+
+// Entries возвращает все записи, от новых к старым.
+func (c *Cache) Entries() []CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]CacheEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+	return entries
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{Entries: len(c.entries)}
+	for _, entry := range c.entries {
+		if c.expired(entry) {
+			stats.Expired++
+		}
+		stats.Hits += entry.Hits
+	}
+	return stats
+}
+
+// Prune удаляет устаревшие записи и возвращает их количество.
+func (c *Cache) Prune() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, entry := range c.entries {
+		if c.expired(entry) {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	return removed, c.save()
+}
+
+func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*CacheEntry)
+	return c.save()
+}
+
+func (c *Cache) expired(entry *CacheEntry) bool {
+	return c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl
+}
+
+// save пишет кеш во временный файл и переименовывает его, чтобы не оставить
+// полузаписанный файл при падении.
+func (c *Cache) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return os.Rename(tmp, c.path)
+}
+
+func cacheKey(prompts *Prompts, persona, question, givenAnswer, rightAnswer string) string {
+	h := sha256.New()
+	for _, part := range []string{prompts.ID(), cacheModel()} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	for _, part := range []string{persona, question, rightAnswer, givenAnswer} {
+		h.Write([]byte(normalize(part)))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheModel - провайдер и модель, которые сейчас выносят вердикты.
+func cacheModel() string {
+	cfg, _ := currentConfig().WithDefaults()
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderAnthropic
+	}
+	return cfg.Provider + "/" + cfg.Model
+}
+
+// normalize приводит текст к нижнему регистру, заменяет ё на е, убирает
+// пунктуацию и лишние пробелы.
+func normalize(s string) string {
+	s = strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
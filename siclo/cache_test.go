@@ -0,0 +1,54 @@
+package siclo
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestCacheKeySeparatesPromptsAndModels проверяет, что вердикт, вынесенный
+// с одним набором промптов или одной моделью, не достается другим.
+func TestCacheKeySeparatesPromptsAndModels(t *testing.T) {
+	t.Cleanup(func() { Configure(DefaultConfig()) })
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "cache.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	en, err := LoadPrompts("", "en", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Configure(DefaultConfig())
+	if err := cache.Put(nil, "Ведущий", "", "Столица Франции?", "Париж", "Париж", ValidationResult{Result: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get(DefaultPrompts(), "Ведущий", "", "Столица Франции?", "париж!", "Париж"); !ok {
+		t.Error("same prompts and model: verdict not found")
+	}
+	if _, ok := cache.Get(en, "Ведущий", "", "Столица Франции?", "Париж", "Париж"); ok {
+		t.Error("other language: cached verdict served")
+	}
+
+	cfg := DefaultConfig()
+	cfg.Provider = ProviderOpenAI
+	cfg.Model = "local"
+	Configure(cfg)
+	if _, ok := cache.Get(nil, "Ведущий", "", "Столица Франции?", "Париж", "Париж"); ok {
+		t.Error("other model: cached verdict served")
+	}
+}
+
+func TestCachedVerdictIsFree(t *testing.T) {
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "cache.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := ValidationResult{Result: true, Usage: Usage{InputTokens: 1000, OutputTokens: 500}}
+	if err := cache.Put(nil, "Ведущий", "", "Столица Франции?", "Париж", "Париж", result); err != nil {
+		t.Fatal(err)
+	}
+	cached, ok := cache.Get(nil, "Ведущий", "", "Столица Франции?", "Париж", "Париж")
+	if !ok || !cached.Result || cached.Usage != (Usage{}) {
+		t.Errorf("cached = %+v, %t, want free verdict", cached, ok)
+	}
+}
@@ -0,0 +1,59 @@
+package siclo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Character - персонаж из npc_characters/characters.json.
+type Character struct {
+	Name         string `json:"name"`
+	HostPrompt   string `json:"host_prompt"`
+	PlayerPrompt string `json:"player_prompt"`
+}
+
+// Роли, в которых выступает персонаж.
+const (
+	PersonaHost   = "host"
+	PersonaPlayer = "player"
+)
+
+// Persona возвращает описание персонажа в роли persona: PersonaHost
+// (host_prompt) или PersonaPlayer (player_prompt). Без промпта роли
+// персонаж описывается одним именем.
+func (c Character) Persona(persona string) string {
+	prompt := c.HostPrompt
+	if persona == PersonaPlayer {
+		prompt = c.PlayerPrompt
+	}
+	if prompt != "" {
+		return prompt
+	}
+	return "ты " + c.Name
+}
+
+// LoadCharacters читает список персонажей из characters.json.
+func LoadCharacters(path string) ([]Character, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read characters: %w", err)
+	}
+
+	var characters []Character
+	if err := json.Unmarshal(data, &characters); err != nil {
+		return nil, fmt.Errorf("failed to parse characters: %w", err)
+	}
+	return characters, nil
+}
+
+// FindCharacter ищет персонажа по имени без учета регистра.
+func FindCharacter(characters []Character, name string) (Character, bool) {
+	for _, c := range characters {
+		if strings.EqualFold(c.Name, strings.TrimSpace(name)) {
+			return c, true
+		}
+	}
+	return Character{}, false
+}
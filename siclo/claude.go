@@ -1,67 +1,112 @@
 package siclo
 
 import (
-    "encoding/json"
-	"context"
+	"encoding/json"
 	"fmt"
-    "os"
-
-
-	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/option"
+	"strings"
 )
 
-func ValidateAnswer(characterName, characterPrompt, question, givenAnswer, rightAnswer string) (*ValidationResult, error) {
-	client := anthropic.NewClient(
-		option.WithAPIKey(os.Getenv("ANTHROPIC_API_KEY")),
-	)
-
-    prompt := fmt.Sprintf(`
-Ты - %s, и от тебя требуется решить, дал ли игрок верный ответ в таком формате json:
-
-{
-    "result": true,
-    "justification": "Да, то что вы сказали верно, в 1993 году этот фильм уже был в прокате"
+type validatePrompt struct {
+	Name     string
+	Persona  string
+	Question string
+	Expected string
+	Given    string
 }
-или
-{
-    "result": false,
-    "justification": "Нет это не так, вы ошиблись на 2 года"
+
+func ValidateAnswer(prompts *Prompts, characterName, characterPrompt, question, givenAnswer, rightAnswer string) (*ValidationResult, error) {
+	return validateAnswer(prompts, characterName, characterPrompt, question, givenAnswer, rightAnswer, nil)
 }
 
-При заполнения поля justification помни, что %s
+// ValidateAnswerStream работает как ValidateAnswer, но отдает justification
+// в onDelta по кускам, пока модель его пишет. Для вердиктов из кеша и
+// строгой проверки onDelta не вызывается.
+func ValidateAnswerStream(prompts *Prompts, characterName, characterPrompt, question, givenAnswer, rightAnswer string, onDelta StreamFunc) (*ValidationResult, error) {
+	return validateAnswer(prompts, characterName, characterPrompt, question, givenAnswer, rightAnswer, onDelta)
+}
 
-вот пришедший запрос:
+func validateAnswer(prompts *Prompts, characterName, characterPrompt, question, givenAnswer, rightAnswer string, onDelta StreamFunc) (*ValidationResult, error) {
+	if DetectInjection(givenAnswer) {
+		return &ValidationResult{
+			Result:    MatchLocally(givenAnswer, rightAnswer),
+			Injection: true,
+		}, nil
+	}
 
-{
-    "question": "%s",
-    "expected-answer": "%s",
-    "given-answer": "%s"
-}
-        `, characterName, characterPrompt, question, rightAnswer, givenAnswer)
+	if verdictCache != nil {
+		if result, ok := verdictCache.Get(prompts, characterName, characterPrompt, question, givenAnswer, rightAnswer); ok {
+			result.Cached = true
+			return result, nil
+		}
+	}
 
-	message, err := client.Messages.New(context.TODO(), anthropic.MessageNewParams{
-		MaxTokens: 1024,
-		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
-		},
-		Model: "claude-sonnet-4-20250514",
+	prompt, err := prompts.render("validate", validatePrompt{
+		Name:     characterName,
+		Persona:  characterPrompt,
+		Question: question,
+		Expected: rightAnswer,
+		Given:    givenAnswer,
 	})
 	if err != nil {
-		panic(err.Error())
+		return nil, err
+	}
+
+	var text string
+	var usage Usage
+	if onDelta != nil {
+		guard := newLeakGuard(rightAnswer, onDelta)
+		text, usage, err = completeStream(prompt, "justification", guard.write)
+		guard.flush()
+	} else {
+		text, usage, err = complete(prompt)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result ValidationResult
+	if err := decodeJSON(text, &result); err != nil {
+		return nil, err
+	}
+	result.Usage = usage
+	result.Clarify = result.Clarify && !result.Result
+
+	// Ведущий проговорился: без стрима можно попросить реплику еще раз,
+	// вердикт при этом оставляем прежним
+	if onDelta == nil && DetectLeak(result.Justification, rightAnswer) {
+		if text, usage, err := complete(prompt); err == nil {
+			result.Usage = result.Usage.Add(usage)
+			var retry ValidationResult
+			if decodeJSON(text, &retry) == nil {
+				result.Justification = retry.Justification
+			}
+		}
+	}
+	result.Justification, result.Redacted = RedactLeaks(result.Justification, rightAnswer)
+
+	if verdictCache != nil {
+		// запись в память уже прошла, ошибка сохранения на диск не должна ломать проверку
+		_ = verdictCache.Put(prompts, characterName, characterPrompt, question, givenAnswer, rightAnswer, result)
 	}
 
-    text := message.Content[0].Text
-    var result ValidationResult
+	return &result, nil
+}
+
+// decodeJSON разбирает JSON из ответа модели. Модель иногда оборачивает его
+// в ```json ... ``` или добавляет текст вокруг, поэтому берем первый объект.
+func decodeJSON(text string, v interface{}) error {
+	raw := text
+	if start, end := strings.Index(raw, "{"), strings.LastIndex(raw, "}"); start >= 0 && end > start {
+		raw = raw[start : end+1]
+	}
 
-    err = json.Unmarshal([]byte(text), &result)
-    if err != nil {
-        return nil, fmt.Errorf(
-            "failed to parse model JSON: %w\nraw: %s",
-            err,
-            text,
-        )
-    }
+	if err := json.Unmarshal([]byte(raw), v); err != nil {
+		return fmt.Errorf(
+			"failed to parse model JSON: %w\nraw: %s",
+			err,
+			text,
+		)
+	}
 
-    return &result, nil
-}
\ No newline at end of file
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"siclo"
+)
+
+const defaultCachePath = "siclo_cache.json"
+
+func runCache(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: siclo-cli cache <stats|list|prune|clear> [-path file]")
+		os.Exit(1)
+	}
+
+	command := args[0]
+	fs := flag.NewFlagSet("cache "+command, flag.ExitOnError)
+	path := fs.String("path", defaultCachePath, "Verdict cache file")
+	ttl := fs.Duration("ttl", siclo.DefaultCacheTTL, "Verdict cache entry lifetime")
+	jsonOutput := fs.Bool("json", false, "Output raw JSON")
+	fs.Parse(args[1:])
+
+	cache, err := siclo.OpenCache(*path, *ttl)
+	exitOnError(err)
+
+	switch command {
+	case "stats":
+		stats := cache.Stats()
+		if *jsonOutput {
+			printJSON(stats)
+			return
+		}
+		fmt.Printf("entries: %d\nexpired: %d\nhits:    %d\n", stats.Entries, stats.Expired, stats.Hits)
+
+	case "list":
+		entries := cache.Entries()
+		if *jsonOutput {
+			printJSON(entries)
+			return
+		}
+		for _, entry := range entries {
+			mark := "❌"
+			if entry.Result.Result {
+				mark = "✅"
+			}
+			fmt.Printf("%s %s | %q -> %q (hits: %d, %s)\n",
+				mark, entry.Question, entry.Given, entry.Expected, entry.Hits,
+				entry.CreatedAt.Format("2006-01-02 15:04"))
+		}
+
+	case "prune":
+		removed, err := cache.Prune()
+		exitOnError(err)
+		fmt.Printf("Removed %d expired entries\n", removed)
+
+	case "clear":
+		exitOnError(cache.Clear())
+		fmt.Println("Cache cleared")
+
+	default:
+		fmt.Fprintln(os.Stderr, "Unknown cache command:", command)
+		os.Exit(1)
+	}
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"siclo"
+)
+
+// evalCase - одна строка датасета: спорный ответ и вердикт, который считаем верным.
+type evalCase struct {
+	Question string   `json:"question"`
+	Accepted []string `json:"accepted"`
+	Given    string   `json:"given"`
+	Verdict  bool     `json:"verdict"`
+}
+
+type evalOutcome struct {
+	Line      int           `json:"line"`
+	Case      evalCase      `json:"case"`
+	Predicted bool          `json:"predicted"`
+	Comment   string        `json:"comment,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Latency   time.Duration `json:"latency"`
+	usage     siclo.Usage
+}
+
+type confusionMatrix struct {
+	TruePositive  int `json:"true_positive"`
+	FalsePositive int `json:"false_positive"`
+	FalseNegative int `json:"false_negative"`
+	TrueNegative  int `json:"true_negative"`
+}
+
+type evalReport struct {
+	Backend      string          `json:"backend"`
+	Cases        int             `json:"cases"`
+	Errors       int             `json:"errors"`
+	Accuracy     float64         `json:"accuracy"`
+	Confusion    confusionMatrix `json:"confusion"`
+	LatencyP50   time.Duration   `json:"latency_p50"`
+	LatencyP90   time.Duration   `json:"latency_p90"`
+	LatencyP99   time.Duration   `json:"latency_p99"`
+	LatencyMax   time.Duration   `json:"latency_max"`
+	Usage        siclo.Usage     `json:"usage"`
+	Cost         float64         `json:"cost"`
+	Disagreement []evalOutcome   `json:"disagreement"`
+}
+
+func runEval(args []string) {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	dataset := fs.String("dataset", "", "JSONL dataset: question, accepted, given, verdict")
+	backend := fs.String("backend", "llm", "Validator backend: llm or local")
+	concurrency := fs.Int("concurrency", 4, "Parallel validations")
+	characterName := fs.String("character-name", "Ведущий", "Host character name")
+	characterPrompt := fs.String("character-prompt", "ты строгий, но справедливый ведущий", "Host character behavior description")
+	jsonOutput := fs.Bool("json", false, "Output raw JSON")
+	sicloFlags := registerSicloFlags(fs)
+	fs.Parse(args)
+
+	if *dataset == "" {
+		fmt.Fprintln(os.Stderr, "Missing -dataset")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var validate func(c evalCase) (*siclo.ValidationResult, error)
+	switch *backend {
+	case "llm":
+		prompts := sicloFlags.apply()
+		validate = func(c evalCase) (*siclo.ValidationResult, error) {
+			return siclo.ValidateAnswer(prompts, *characterName, *characterPrompt, c.Question, c.Given, strings.Join(c.Accepted, " / "))
+		}
+	case "local":
+		validate = func(c evalCase) (*siclo.ValidationResult, error) {
+			return &siclo.ValidationResult{Result: siclo.MatchLocally(c.Given, strings.Join(c.Accepted, "/"))}, nil
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "Unknown backend:", *backend)
+		os.Exit(1)
+	}
+
+	cases, err := readDataset(*dataset)
+	exitOnError(err)
+
+	outcomes := evaluate(cases, validate, max(*concurrency, 1))
+	report := buildReport(*backend, outcomes)
+
+	if *jsonOutput {
+		printJSON(report)
+		return
+	}
+	printReport(report)
+}
+
+func readDataset(path string) ([]evalCase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cases []evalCase
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var c evalCase
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		cases = append(cases, c)
+	}
+
+	return cases, scanner.Err()
+}
+
+// evaluate прогоняет датасет через validate, не больше concurrency проверок сразу.
+func evaluate(cases []evalCase, validate func(c evalCase) (*siclo.ValidationResult, error), concurrency int) []evalOutcome {
+	outcomes := make([]evalOutcome, len(cases))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var done atomic.Int32
+
+	for i, c := range cases {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, c evalCase) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			result, err := validate(c)
+			outcome := evalOutcome{Line: i + 1, Case: c, Latency: time.Since(start)}
+			if err != nil {
+				outcome.Error = err.Error()
+			} else {
+				outcome.Predicted = result.Result
+				outcome.Comment = result.Justification
+				outcome.usage = result.Usage
+			}
+			outcomes[i] = outcome
+			fmt.Fprintf(os.Stderr, "\r%d/%d", done.Add(1), len(cases))
+		}(i, c)
+	}
+
+	wg.Wait()
+	fmt.Fprintln(os.Stderr)
+	return outcomes
+}
+
+func buildReport(backend string, outcomes []evalOutcome) evalReport {
+	report := evalReport{Backend: backend, Cases: len(outcomes)}
+	latencies := make([]time.Duration, 0, len(outcomes))
+	correct := 0
+
+	for _, o := range outcomes {
+		report.Usage = report.Usage.Add(o.usage)
+		if o.Error != "" {
+			report.Errors++
+			report.Disagreement = append(report.Disagreement, o)
+			continue
+		}
+		latencies = append(latencies, o.Latency)
+
+		switch {
+		case o.Case.Verdict && o.Predicted:
+			report.Confusion.TruePositive++
+		case !o.Case.Verdict && o.Predicted:
+			report.Confusion.FalsePositive++
+		case o.Case.Verdict && !o.Predicted:
+			report.Confusion.FalseNegative++
+		default:
+			report.Confusion.TrueNegative++
+		}
+
+		if o.Predicted == o.Case.Verdict {
+			correct++
+		} else {
+			report.Disagreement = append(report.Disagreement, o)
+		}
+	}
+
+	report.Cost = report.Usage.Cost()
+	if len(outcomes) > 0 {
+		report.Accuracy = float64(correct) / float64(len(outcomes))
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	report.LatencyP50 = percentile(latencies, 0.50)
+	report.LatencyP90 = percentile(latencies, 0.90)
+	report.LatencyP99 = percentile(latencies, 0.99)
+	report.LatencyMax = percentile(latencies, 1)
+
+	return report
+}
+
+// percentile берет значение из отсортированного списка.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p*float64(len(sorted)-1) + 0.5)
+	return sorted[idx]
+}
+
+func printReport(r evalReport) {
+	fmt.Printf("backend:  %s\n", r.Backend)
+	fmt.Printf("cases:    %d (errors: %d)\n", r.Cases, r.Errors)
+	fmt.Printf("accuracy: %.1f%%\n", r.Accuracy*100)
+	fmt.Println()
+	fmt.Println("                 predicted ✅  predicted ❌")
+	fmt.Printf("expected ✅      %12d  %12d\n", r.Confusion.TruePositive, r.Confusion.FalseNegative)
+	fmt.Printf("expected ❌      %12d  %12d\n", r.Confusion.FalsePositive, r.Confusion.TrueNegative)
+	fmt.Println()
+	fmt.Printf("latency:  p50 %v, p90 %v, p99 %v, max %v\n",
+		r.LatencyP50.Round(time.Millisecond), r.LatencyP90.Round(time.Millisecond),
+		r.LatencyP99.Round(time.Millisecond), r.LatencyMax.Round(time.Millisecond))
+	fmt.Printf("tokens:   %d in, %d out ($%.4f)\n", r.Usage.InputTokens, r.Usage.OutputTokens, r.Cost)
+
+	if len(r.Disagreement) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("disagreements:")
+	for _, o := range r.Disagreement {
+		if o.Error != "" {
+			fmt.Printf("  #%d %q -> %q: error: %s\n", o.Line, o.Case.Given, o.Case.Accepted, o.Error)
+			continue
+		}
+		fmt.Printf("  #%d %s\n     %q vs %q: expected %t, got %t\n",
+			o.Line, o.Case.Question, o.Case.Given, o.Case.Accepted, o.Case.Verdict, o.Predicted)
+		if o.Comment != "" {
+			fmt.Printf("     %s\n", o.Comment)
+		}
+	}
+}
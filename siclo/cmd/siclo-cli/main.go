@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -10,12 +9,27 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "cache":
+			runCache(os.Args[2:])
+			return
+		case "eval":
+			runEval(os.Args[2:])
+			return
+		case "repl":
+			runRepl(os.Args[2:])
+			return
+		}
+	}
+
 	characterName := flag.String("character-name", "", "Character name")
 	characterPrompt := flag.String("character-prompt", "", "Character behavior description")
 	question := flag.String("question", "", "Question text")
 	rightAnswer := flag.String("right-answer", "", "Correct answer")
 	givenAnswer := flag.String("given-answer", "", "Player answer")
 	jsonOutput := flag.Bool("json", false, "Output raw JSON")
+	sicloFlags := registerSicloFlags(flag.CommandLine)
 
 	flag.Parse()
 
@@ -30,30 +44,43 @@ func main() {
 		os.Exit(1)
 	}
 
+	prompts := sicloFlags.apply()
+
 	result, err := siclo.ValidateAnswer(
+		prompts,
 		*characterName,
 		*characterPrompt,
 		*question,
 		*givenAnswer,
 		*rightAnswer,
 	)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
-		os.Exit(1)
-	}
+	exitOnError(err)
 
 	if *jsonOutput {
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
-		_ = enc.Encode(result)
+		printJSON(result)
 		return
 	}
 
-	// Human-friendly output
+	printVerdict(result)
+}
+
+// printVerdict печатает вердикт, реплику ведущего и расход токенов.
+func printVerdict(result *siclo.ValidationResult) {
 	if result.Result {
 		fmt.Println("✅ Correct")
+	} else if result.Clarify {
+		fmt.Println("❔ Needs clarification")
 	} else {
 		fmt.Println("❌ Incorrect")
 	}
 	fmt.Println(result.Justification)
+
+	if result.Injection {
+		fmt.Println("(prompt injection detected, validated locally)")
+	} else if result.Cached {
+		fmt.Println("(cached)")
+	} else {
+		fmt.Printf("tokens: %d in, %d out ($%.4f)\n",
+			result.Usage.InputTokens, result.Usage.OutputTokens, result.Usage.Cost())
+	}
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"siclo"
+)
+
+const defaultCharactersPath = "../server/npc_characters/characters.json"
+
+const replHelp = `Enter a question, the expected answer and the given answer, one per line.
+An empty question repeats the previous question and expected answer.
+As a player the character answers the question itself, as NPCs do in a game,
+and its answer is validated with its host prompt.
+Commands:
+  :host              validate given answers with the character's host prompt
+  :player            let the character answer with its player prompt
+  :character <name>  switch character
+  :list              list characters
+  :help              show this help
+  :quit              exit`
+
+// repl - песочница для подбора промптов персонажей без целой игры.
+type repl struct {
+	characters []siclo.Character
+	character  siclo.Character
+	persona    string
+	prompts    *siclo.Prompts
+	question   string
+	expected   string
+	in         *bufio.Scanner
+}
+
+func runRepl(args []string) {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	charactersPath := fs.String("characters", defaultCharactersPath, "characters.json with host and player prompts")
+	characterName := fs.String("character", "", "Character name (first character if empty)")
+	persona := fs.String("persona", siclo.PersonaHost, "Character persona: host validates given answers, player answers the question itself")
+	sicloFlags := registerSicloFlags(fs)
+	fs.Parse(args)
+
+	characters, err := siclo.LoadCharacters(*charactersPath)
+	exitOnError(err)
+	if len(characters) == 0 {
+		exitOnError(fmt.Errorf("no characters in %s", *charactersPath))
+	}
+
+	r := &repl{
+		characters: characters,
+		character:  characters[0],
+		in:         bufio.NewScanner(os.Stdin),
+	}
+	if *characterName != "" {
+		c, ok := siclo.FindCharacter(characters, *characterName)
+		if !ok {
+			exitOnError(fmt.Errorf("character %q not found", *characterName))
+		}
+		r.character = c
+	}
+	if err := r.setPersona(*persona); err != nil {
+		exitOnError(err)
+	}
+
+	r.prompts = sicloFlags.apply()
+
+	fmt.Println(replHelp)
+	r.run()
+}
+
+func (r *repl) run() {
+	for {
+		question, ok := r.read(fmt.Sprintf("\n%s (%s) question> ", r.character.Name, r.persona))
+		if !ok {
+			return
+		}
+
+		if strings.HasPrefix(question, ":") {
+			if !r.command(question) {
+				return
+			}
+			continue
+		}
+
+		if question == "" {
+			if r.question == "" {
+				continue
+			}
+			fmt.Printf("question: %s\nexpected: %s\n", r.question, r.expected)
+		} else {
+			expected, ok := r.read("expected> ")
+			if !ok {
+				return
+			}
+			r.question, r.expected = question, expected
+		}
+
+		if r.persona == siclo.PersonaPlayer {
+			r.answer()
+			continue
+		}
+
+		given, ok := r.read("given> ")
+		if !ok {
+			return
+		}
+		r.validate(given)
+	}
+}
+
+func (r *repl) read(prompt string) (string, bool) {
+	fmt.Print(prompt)
+	if !r.in.Scan() {
+		fmt.Println()
+		return "", false
+	}
+	return strings.TrimSpace(r.in.Text()), true
+}
+
+// command выполняет команду и возвращает false, если пора выходить.
+func (r *repl) command(line string) bool {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+
+	switch name {
+	case siclo.PersonaHost, siclo.PersonaPlayer:
+		r.setPersona(name)
+	case "character":
+		c, ok := siclo.FindCharacter(r.characters, arg)
+		if !ok {
+			fmt.Printf("character %q not found\n", arg)
+			break
+		}
+		r.character = c
+	case "list":
+		for _, c := range r.characters {
+			fmt.Println(c.Name)
+		}
+	case "help":
+		fmt.Println(replHelp)
+	case "quit", "q", "exit":
+		return false
+	default:
+		fmt.Printf("unknown command :%s, see :help\n", name)
+	}
+	return true
+}
+
+func (r *repl) setPersona(persona string) error {
+	if persona != siclo.PersonaHost && persona != siclo.PersonaPlayer {
+		return fmt.Errorf("unknown persona %q, want host or player", persona)
+	}
+	r.persona = persona
+	return nil
+}
+
+// answer дает персонажу ответить на вопрос, как NPC в игре, и проверяет
+// его ответ от лица того же персонажа-ведущего.
+func (r *repl) answer() {
+	start := time.Now()
+	answer, err := siclo.GenerateAnswer(r.prompts, r.character, r.question, "", 0)
+	latency := time.Since(start)
+
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	if !answer.Buzz {
+		fmt.Printf("no buzz (confidence %.2f)\n", answer.Confidence)
+	} else {
+		fmt.Printf("answer: %s (confidence %.2f, buzz after %v)\n", answer.Answer, answer.Confidence, answer.Delay.Round(time.Millisecond))
+	}
+	fmt.Printf("tokens: %d in, %d out ($%.4f)\nlatency: %v\n",
+		answer.Usage.InputTokens, answer.Usage.OutputTokens, answer.Usage.Cost(), latency.Round(time.Millisecond))
+
+	if answer.Buzz {
+		r.validate(answer.Answer)
+	}
+}
+
+func (r *repl) validate(given string) {
+	start := time.Now()
+	result, err := siclo.ValidateAnswer(r.prompts, r.character.Name, r.character.Persona(siclo.PersonaHost), r.question, given, r.expected)
+	latency := time.Since(start)
+
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	printVerdict(result)
+	fmt.Printf("latency: %v\n", latency.Round(time.Millisecond))
+}
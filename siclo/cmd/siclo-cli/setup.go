@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"siclo"
+)
+
+// sicloFlags - общие для команд флаги модели, промптов и кеша.
+type sicloFlags struct {
+	cfg         siclo.Config
+	promptsDir  *string
+	language    *string
+	packageName *string
+	cachePath   *string
+	cacheTTL    *time.Duration
+}
+
+func registerSicloFlags(fs *flag.FlagSet) *sicloFlags {
+	cfg, err := siclo.ConfigFromEnv(siclo.DefaultConfig())
+	exitOnError(err)
+
+	f := &sicloFlags{
+		cfg:         cfg,
+		promptsDir:  fs.String("prompts", "", "Prompt templates directory (built-in prompts if empty)"),
+		language:    fs.String("lang", siclo.DefaultLanguage, "Prompt language"),
+		packageName: fs.String("package", "", "Package name for package-specific prompts"),
+		cachePath:   fs.String("cache", "", "Verdict cache file (disabled if empty)"),
+		cacheTTL:    fs.Duration("cache-ttl", siclo.DefaultCacheTTL, "Verdict cache entry lifetime"),
+	}
+	f.cfg.RegisterFlags(fs)
+	return f
+}
+
+// apply настраивает siclo после разбора флагов и возвращает шаблоны
+// промптов, с которыми идут запросы к модели.
+func (f *sicloFlags) apply() *siclo.Prompts {
+	cfg, err := f.cfg.WithDefaults()
+	exitOnError(err)
+	siclo.Configure(cfg)
+
+	prompts, err := siclo.LoadPrompts(*f.promptsDir, *f.language, *f.packageName)
+	exitOnError(err)
+
+	if *f.cachePath != "" {
+		cache, err := siclo.OpenCache(*f.cachePath, *f.cacheTTL)
+		exitOnError(err)
+		siclo.UseCache(cache)
+	}
+	return prompts
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,191 @@
+package siclo
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Config - параметры модели и подключения к ней.
+type Config struct {
+	// Provider - API модели: ProviderAnthropic (по умолчанию) или
+	// ProviderOpenAI для серверов, совместимых с OpenAI chat completions.
+	Provider string
+	// Model пустая - модель провайдера по умолчанию, см. WithDefaults.
+	Model     string
+	MaxTokens int64
+	// Temperature < 0 означает температуру модели по умолчанию.
+	Temperature float64
+	// BaseURL позволяет направить запросы на локальную заглушку или прокси.
+	BaseURL string
+	// Timeout - сколько ждать ответа модели целиком, 0 - без ограничения.
+	Timeout time.Duration
+	// Ключ берется из APIKey, если он задан, иначе из файла APIKeyFile,
+	// иначе из переменной окружения APIKeyEnv. Без APIKeyEnv ключ ищется
+	// в ANTHROPIC_API_KEY или OPENAI_API_KEY, смотря по провайдеру.
+	APIKey     string
+	APIKeyFile string
+	APIKeyEnv  string
+	// System - системный промпт, добавляемый к каждому запросу.
+	System string
+	// Цены в долларах за миллион токенов, для подсчета стоимости игры.
+	InputPrice  float64
+	OutputPrice float64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Provider:    ProviderAnthropic,
+		MaxTokens:   1024,
+		Temperature: -1,
+		Timeout:     2 * time.Minute,
+		InputPrice:  3,
+		OutputPrice: 15,
+	}
+}
+
+// defaultModels - модели провайдеров по умолчанию. У серверов, совместимых
+// с OpenAI, общей модели нет, для них модель задается явно.
+var defaultModels = map[string]string{
+	"":                "claude-sonnet-4-20250514",
+	ProviderAnthropic: "claude-sonnet-4-20250514",
+}
+
+// WithDefaults подставляет модель провайдера, если она не задана, и
+// возвращает ошибку, если у провайдера модели по умолчанию нет.
+func (cfg Config) WithDefaults() (Config, error) {
+	if cfg.Model != "" {
+		return cfg, nil
+	}
+	model, ok := defaultModels[cfg.Provider]
+	if !ok {
+		return cfg, fmt.Errorf("model is required for provider %q", cfg.Provider)
+	}
+	cfg.Model = model
+	return cfg, nil
+}
+
+var config atomic.Pointer[Config]
+
+func init() {
+	Configure(DefaultConfig())
+}
+
+// Configure задает параметры модели для всех последующих запросов. Модель
+// одна на процесс, поэтому Configure вызывается при старте, а не для игры.
+func Configure(cfg Config) {
+	config.Store(&cfg)
+}
+
+// currentConfig - параметры, заданные последним Configure.
+func currentConfig() Config {
+	return *config.Load()
+}
+
+// ConfigFromEnv дополняет cfg значениями из переменных окружения SICLO_*.
+func ConfigFromEnv(cfg Config) (Config, error) {
+	if v := os.Getenv("SICLO_PROVIDER"); v != "" {
+		cfg.Provider = v
+	}
+	if v := os.Getenv("SICLO_MODEL"); v != "" {
+		cfg.Model = v
+	}
+	if v := os.Getenv("SICLO_MAX_TOKENS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid SICLO_MAX_TOKENS: %w", err)
+		}
+		cfg.MaxTokens = n
+	}
+	if v := os.Getenv("SICLO_TEMPERATURE"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid SICLO_TEMPERATURE: %w", err)
+		}
+		cfg.Temperature = t
+	}
+	if v := os.Getenv("SICLO_BASE_URL"); v != "" {
+		cfg.BaseURL = v
+	}
+	if v := os.Getenv("SICLO_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid SICLO_TIMEOUT: %w", err)
+		}
+		cfg.Timeout = d
+	}
+	if v := os.Getenv("SICLO_API_KEY_FILE"); v != "" {
+		cfg.APIKeyFile = v
+	}
+	if v := os.Getenv("SICLO_API_KEY_ENV"); v != "" {
+		cfg.APIKeyEnv = v
+	}
+	if v := os.Getenv("SICLO_SYSTEM_PROMPT"); v != "" {
+		cfg.System = v
+	}
+	if v := os.Getenv("SICLO_INPUT_PRICE"); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid SICLO_INPUT_PRICE: %w", err)
+		}
+		cfg.InputPrice = price
+	}
+	if v := os.Getenv("SICLO_OUTPUT_PRICE"); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid SICLO_OUTPUT_PRICE: %w", err)
+		}
+		cfg.OutputPrice = price
+	}
+	return cfg, nil
+}
+
+// RegisterFlags добавляет флаги модели в fs. Значения cfg служат значениями
+// по умолчанию, поэтому флаги перекрывают переменные окружения.
+func (cfg *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Provider, "provider", cfg.Provider, "Model API: anthropic or openai (OpenAI-compatible chat completions)")
+	fs.StringVar(&cfg.Model, "model", cfg.Model, "Model name (default depends on provider, required for openai)")
+	fs.Int64Var(&cfg.MaxTokens, "max-tokens", cfg.MaxTokens, "Max tokens in model response")
+	fs.Float64Var(&cfg.Temperature, "temperature", cfg.Temperature, "Sampling temperature (negative for model default)")
+	fs.StringVar(&cfg.BaseURL, "base-url", cfg.BaseURL, "Model API base URL")
+	fs.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout, "Model request timeout (0 for none)")
+	fs.StringVar(&cfg.APIKeyFile, "api-key-file", cfg.APIKeyFile, "File with the API key")
+	fs.StringVar(&cfg.APIKeyEnv, "api-key-env", cfg.APIKeyEnv, "Environment variable with the API key (default ANTHROPIC_API_KEY or OPENAI_API_KEY)")
+	fs.StringVar(&cfg.System, "system", cfg.System, "System prompt")
+	fs.Float64Var(&cfg.InputPrice, "input-price", cfg.InputPrice, "USD per million input tokens")
+	fs.Float64Var(&cfg.OutputPrice, "output-price", cfg.OutputPrice, "USD per million output tokens")
+}
+
+// requestContext - контекст одного запроса к модели с ограничением Timeout.
+func (cfg Config) requestContext() (context.Context, context.CancelFunc) {
+	if cfg.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), cfg.Timeout)
+}
+
+func (cfg Config) apiKey() (string, error) {
+	if cfg.APIKey != "" {
+		return cfg.APIKey, nil
+	}
+	if cfg.APIKeyFile != "" {
+		data, err := os.ReadFile(cfg.APIKeyFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read API key file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	env := cfg.APIKeyEnv
+	if env == "" {
+		env = "ANTHROPIC_API_KEY"
+		if cfg.Provider == ProviderOpenAI {
+			env = "OPENAI_API_KEY"
+		}
+	}
+	return os.Getenv(env), nil
+}
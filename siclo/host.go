@@ -0,0 +1,88 @@
+package siclo
+
+import "strings"
+
+type PlayerScore struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+type hostPrompt struct {
+	Name      string
+	Persona   string
+	Situation string
+}
+
+type hostLineResult struct {
+	Text string `json:"text"`
+}
+
+// HostGameStart - приветствие ведущего в начале игры.
+func HostGameStart(prompts *Prompts, host Character, players []string) (*HostLine, error) {
+	return hostLine(prompts, host, "host_start", map[string]interface{}{
+		"Players": players,
+	})
+}
+
+// HostQuestionSelected - реплика ведущего, когда игрок выбрал вопрос.
+func HostQuestionSelected(prompts *Prompts, host Character, player, theme string, price int) (*HostLine, error) {
+	return hostLine(prompts, host, "host_question", map[string]interface{}{
+		"Player": player,
+		"Theme":  theme,
+		"Price":  price,
+	})
+}
+
+// HostRoundChange - объявление нового раунда.
+func HostRoundChange(prompts *Prompts, host Character, round int, themes []string) (*HostLine, error) {
+	return hostLine(prompts, host, "host_round", map[string]interface{}{
+		"Round":  round,
+		"Themes": themes,
+	})
+}
+
+// HostNobodyAnswered - реплика ведущего, когда на вопрос никто не ответил.
+// Время на вопрос вышло, поэтому правильный ответ называть можно.
+func HostNobodyAnswered(prompts *Prompts, host Character, question, answer string) (*HostLine, error) {
+	return hostLine(prompts, host, "host_no_answer", map[string]interface{}{
+		"Question": question,
+		"Answer":   answer,
+	})
+}
+
+// HostGameEnd - прощание ведущего и подведение итогов.
+func HostGameEnd(prompts *Prompts, host Character, scores []PlayerScore) (*HostLine, error) {
+	return hostLine(prompts, host, "host_end", map[string]interface{}{
+		"Scores": scores,
+	})
+}
+
+// hostLine подставляет описание ситуации из шаблона situation в общий
+// шаблон реплики ведущего.
+func hostLine(prompts *Prompts, host Character, situation string, data map[string]interface{}) (*HostLine, error) {
+	text, err := prompts.render(situation, data)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := prompts.render("host", hostPrompt{
+		Name:      host.Name,
+		Persona:   host.Persona(PersonaHost),
+		Situation: strings.TrimSpace(text),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	text, usage, err := complete(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var result hostLineResult
+	if err := decodeJSON(text, &result); err != nil {
+		return nil, err
+	}
+
+	return &HostLine{Text: strings.TrimSpace(result.Text), Usage: usage}, nil
+}
@@ -0,0 +1,31 @@
+package siclo
+
+import (
+	"regexp"
+	"strings"
+)
+
+// injectionPatterns - признаки попытки переписать запрос к модели вместо ответа
+// на вопрос: поля JSON из запроса и обращения к модели на русском и английском.
+// Ловим только сами приемы: ответ про инструкции или со скобками - обычный ответ.
+var injectionPatterns = []*regexp.Regexp{
+	// "ключ": ... или {"ключ" - кусок JSON, которым дописывают свои поля
+	regexp.MustCompile(`"[\pL_-]+"\s*:|\{\s*"`),
+	regexp.MustCompile(`expected[-_]answer|given[-_]answer|"(result|justification)"`),
+	regexp.MustCompile(`ignore\s+(all\s+)?(the\s+)?(previous|above|prior)|disregard\s+(all\s+)?(the\s+)?(previous|above|prior|instructions)|system\s+prompt|you\s+are\s+now|new\s+instructions?\s*:`),
+	regexp.MustCompile(`(say|answer|return|respond)\s+(with\s+)?"?(true|false|correct)\b`),
+	regexp.MustCompile(`игнорируй\s+(вс[её]|предыдущ|инструкц|правил)|забудь\s+(вс[её]|предыдущ|инструкц)|(нов\pL*|предыдущ\pL*)\s+инструкци\pL*\s*:|системн\pL*\s+промпт|ты\s+теперь`),
+	regexp.MustCompile(`(скажи|ответь|верни|напиши)\s+"?(true|false|верно|правильно|да)\b`),
+	regexp.MustCompile(`засчитай|засчитать`),
+}
+
+// DetectInjection сообщает, похож ли ответ игрока на попытку управлять моделью.
+func DetectInjection(givenAnswer string) bool {
+	text := strings.ToLower(givenAnswer)
+	for _, pattern := range injectionPatterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package siclo
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const redactedWord = "…"
+
+var translitTable = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// DetectLeak сообщает, проговорился ли ведущий: есть ли в тексте ответ
+// с карточки, в том числе с опечатками, в другом падеже или латиницей.
+func DetectLeak(text, rightAnswer string) bool {
+	_, leaked := RedactLeaks(text, rightAnswer)
+	return leaked
+}
+
+// RedactLeaks заменяет слова ответа с карточки в тексте на многоточие.
+func RedactLeaks(text, rightAnswer string) (string, bool) {
+	return redactWords(text, answerWords(rightAnswer))
+}
+
+// answerWords - слова ответа, по которым его можно узнать. Однословный ответ
+// берется целиком, из длинных ответов - только слова от 4 букв, чтобы не
+// цеплять предлоги.
+func answerWords(rightAnswer string) []string {
+	var words []string
+	for _, accepted := range splitAccepted(rightAnswer) {
+		parts := strings.Fields(accepted)
+		for _, part := range parts {
+			if len(parts) == 1 || utf8.RuneCountInString(part) >= 4 {
+				words = append(words, transliterate(part))
+			}
+		}
+	}
+	return words
+}
+
+func redactWords(text string, words []string) (string, bool) {
+	if len(words) == 0 {
+		return text, false
+	}
+
+	var out strings.Builder
+	leaked := false
+	start := -1
+
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := text[start:end]
+		if leaks(word, words) {
+			out.WriteString(redactedWord)
+			leaked = true
+		} else {
+			out.WriteString(word)
+		}
+		start = -1
+	}
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+		out.WriteRune(r)
+	}
+	flush(len(text))
+
+	return out.String(), leaked
+}
+
+func leaks(word string, answerWords []string) bool {
+	w := transliterate(normalize(word))
+	for _, a := range answerWords {
+		if w == a {
+			return true
+		}
+		// числа и короткие слова узнаются только целиком: 1813 - не 1812
+		if !stemmable(a) {
+			continue
+		}
+		// другой падеж: Пушкина, Пушкиным
+		_, last := utf8.DecodeLastRuneInString(a)
+		if strings.HasPrefix(w, a[:len(a)-last]) && len(w)-len(a) <= 3 {
+			return true
+		}
+		if levenshtein(w, a) <= typoTolerance(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// stemmable - слово ответа можно узнать по основе и с опечатками: только
+// слово из букв, не короче 5.
+func stemmable(answerWord string) bool {
+	if utf8.RuneCountInString(answerWord) < 5 {
+		return false
+	}
+	for _, r := range answerWord {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if t, ok := translitTable[r]; ok {
+			b.WriteString(t)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// leakGuard пропускает поток речи ведущего по словам и прячет слова ответа
+// до того, как они уйдут игрокам.
+type leakGuard struct {
+	words   []string
+	pending string
+	emit    StreamFunc
+}
+
+func newLeakGuard(rightAnswer string, emit StreamFunc) *leakGuard {
+	return &leakGuard{words: answerWords(rightAnswer), emit: emit}
+}
+
+// write копит текст и отдает его только до конца последнего целого слова.
+func (g *leakGuard) write(delta string) {
+	g.pending += delta
+
+	cut := strings.LastIndexFunc(g.pending, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if cut < 0 {
+		return
+	}
+	_, size := utf8.DecodeRuneInString(g.pending[cut:])
+
+	chunk := g.pending[:cut+size]
+	g.pending = g.pending[cut+size:]
+	g.send(chunk)
+}
+
+func (g *leakGuard) flush() {
+	chunk := g.pending
+	g.pending = ""
+	g.send(chunk)
+}
+
+func (g *leakGuard) send(chunk string) {
+	if chunk == "" {
+		return
+	}
+	redacted, _ := redactWords(chunk, g.words)
+	g.emit(redacted)
+}
@@ -0,0 +1,36 @@
+package siclo
+
+import "testing"
+
+func TestDetectLeak(t *testing.T) {
+	tests := []struct {
+		text, answer string
+		want         bool
+	}{
+		{"Это написал Пушкин.", "Пушкин", true},
+		{"Стихи Пушкина знают все.", "Пушкин", true},
+		{"Речь о Pushkin.", "Пушкин", true},
+		{"Опечатка: Пушкен.", "Александр Пушкин", true},
+		{"Война началась в 1812 году.", "1812", true},
+		{"Это было в 1813 году.", "1812", false},
+		{"Год 18120 тут ни при чем.", "1812", false},
+		{"Столица - Москва.", "Москва", true},
+		{"Из Москвы далеко.", "Москва", true},
+		{"Мост через реку.", "Москва", false},
+		{"Римский сенат.", "Рим", false},
+		{"Ответ про Рим.", "Рим", true},
+		{"Ведущий ничего не сказал.", "Пушкин", false},
+	}
+	for _, tt := range tests {
+		if got := DetectLeak(tt.text, tt.answer); got != tt.want {
+			t.Errorf("DetectLeak(%q, %q) = %v, want %v", tt.text, tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestRedactLeaks(t *testing.T) {
+	got, leaked := RedactLeaks("В 1812 году, а не в 1813.", "1812")
+	if !leaked || got != "В "+redactedWord+" году, а не в 1813." {
+		t.Errorf("RedactLeaks = %q, %v", got, leaked)
+	}
+}
@@ -0,0 +1,72 @@
+package siclo
+
+import "strings"
+
+// MatchLocally проверяет ответ без модели: ответ игрока сравнивается с каждым
+// вариантом с карточки (варианты разделяются "/" или ";") после нормализации,
+// с поправкой на опечатки в длинных ответах.
+func MatchLocally(givenAnswer, rightAnswer string) bool {
+	given := normalize(givenAnswer)
+	if given == "" {
+		return false
+	}
+
+	for _, accepted := range splitAccepted(rightAnswer) {
+		if given == accepted {
+			return true
+		}
+		if levenshtein(given, accepted) <= typoTolerance(accepted) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAccepted возвращает нормализованные варианты ответа с карточки.
+func splitAccepted(rightAnswer string) []string {
+	parts := strings.FieldsFunc(rightAnswer, func(r rune) bool {
+		return r == '/' || r == ';'
+	})
+
+	accepted := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if n := normalize(part); n != "" {
+			accepted = append(accepted, n)
+		}
+	}
+	return accepted
+}
+
+// typoTolerance - сколько опечаток прощается: в коротких ответах ни одной,
+// дальше по одной на каждые 6 букв.
+func typoTolerance(answer string) int {
+	n := len([]rune(answer))
+	if n < 5 {
+		return 0
+	}
+	return n / 6
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,182 @@
+package siclo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// openAIProvider ходит в API, совместимое с OpenAI chat completions:
+// сам OpenAI или локальный сервер вроде llama.cpp (BaseURL http://localhost:8080/v1).
+type openAIProvider struct {
+	cfg Config
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model         string               `json:"model"`
+	Messages      []openAIMessage      `json:"messages"`
+	MaxTokens     int64                `json:"max_tokens,omitempty"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+		Delta   openAIMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+func (u *openAIUsage) usage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+}
+
+func (p openAIProvider) Complete(prompt string) (string, Usage, error) {
+	resp, err := p.post(prompt, false)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var reply openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return "", Usage{}, fmt.Errorf("failed to decode model response: %w", err)
+	}
+
+	usage := reply.Usage.usage()
+	if len(reply.Choices) == 0 {
+		return "", usage, fmt.Errorf("model returned empty response")
+	}
+
+	return reply.Choices[0].Message.Content, usage, nil
+}
+
+// CompleteStream читает ответ в формате server-sent events: строки
+// "data: {...}" с кусками текста и "data: [DONE]" в конце.
+func (p openAIProvider) CompleteStream(prompt string, onText StreamFunc) (string, Usage, error) {
+	resp, err := p.post(prompt, true)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var text strings.Builder
+	var usage Usage
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", Usage{}, fmt.Errorf("model stream failed: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			text.WriteString(chunk.Choices[0].Delta.Content)
+			onText(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", Usage{}, fmt.Errorf("model stream failed: %w", err)
+	}
+
+	if text.Len() == 0 {
+		return "", usage, fmt.Errorf("model returned empty response")
+	}
+	return text.String(), usage, nil
+}
+
+func (p openAIProvider) post(prompt string, stream bool) (*http.Response, error) {
+	cfg := p.cfg
+
+	request := openAIRequest{
+		Model:     cfg.Model,
+		MaxTokens: cfg.MaxTokens,
+		Stream:    stream,
+	}
+	if cfg.System != "" {
+		request.Messages = append(request.Messages, openAIMessage{Role: "system", Content: cfg.System})
+	}
+	request.Messages = append(request.Messages, openAIMessage{Role: "user", Content: prompt})
+	if cfg.Temperature >= 0 {
+		temperature := cfg.Temperature
+		request.Temperature = &temperature
+	}
+	if stream {
+		request.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// локальным серверам ключ обычно не нужен
+	key, err := cfg.apiKey()
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	// Timeout клиента ограничивает и чтение ответа, в том числе потока
+	client := &http.Client{Timeout: cfg.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("model request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("model request failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return resp, nil
+}
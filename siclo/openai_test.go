@@ -0,0 +1,204 @@
+package siclo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openAIServer поднимает заглушку chat completions: проверяет запрос через
+// check и отвечает handler.
+func openAIServer(t *testing.T, check func(r *http.Request, req openAIRequest), handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request %s %s, want POST /v1/chat/completions", r.Method, r.URL.Path)
+		}
+		var req openAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("request body is not json: %v", err)
+		}
+		if check != nil {
+			check(r, req)
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func openAITestConfig(baseURL string) Config {
+	cfg := DefaultConfig()
+	cfg.Provider = ProviderOpenAI
+	cfg.Model = "test-model"
+	cfg.BaseURL = baseURL + "/v1/"
+	cfg.APIKey = "test-key"
+	cfg.System = "system prompt"
+	cfg.Temperature = 0.5
+	return cfg
+}
+
+func TestOpenAIComplete(t *testing.T) {
+	srv := openAIServer(t, func(r *http.Request, req openAIRequest) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		if req.Model != "test-model" || req.MaxTokens != 1024 || req.Stream || req.StreamOptions != nil {
+			t.Errorf("request = %+v", req)
+		}
+		if req.Temperature == nil || *req.Temperature != 0.5 {
+			t.Errorf("temperature = %v, want 0.5", req.Temperature)
+		}
+		want := []openAIMessage{{Role: "system", Content: "system prompt"}, {Role: "user", Content: "prompt"}}
+		if fmt.Sprint(req.Messages) != fmt.Sprint(want) {
+			t.Errorf("messages = %v, want %v", req.Messages, want)
+		}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ответ"}}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`)
+	})
+
+	provider, err := newProvider(openAITestConfig(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, usage, err := provider.Complete("prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "ответ" || usage != (Usage{InputTokens: 12, OutputTokens: 3}) {
+		t.Errorf("Complete = %q, %+v", text, usage)
+	}
+}
+
+func TestOpenAINoKey(t *testing.T) {
+	srv := openAIServer(t, func(r *http.Request, req openAIRequest) {
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization = %q, want none", got)
+		}
+		if req.Temperature != nil || len(req.Messages) != 1 {
+			t.Errorf("request = %+v", req)
+		}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	})
+
+	cfg := openAITestConfig(srv.URL)
+	cfg.APIKey = ""
+	cfg.APIKeyEnv = "SICLO_TEST_NO_SUCH_KEY"
+	cfg.System = ""
+	cfg.Temperature = -1
+	if _, _, err := (openAIProvider{cfg: cfg}).Complete("prompt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"status", http.StatusTooManyRequests, `{"error":"rate limited"}`, "429 Too Many Requests: {\"error\":\"rate limited\"}"},
+		{"malformed json", http.StatusOK, `{"choices":[`, "failed to decode model response"},
+		{"no choices", http.StatusOK, `{"choices":[]}`, "empty response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := openAIServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+			_, _, err := (openAIProvider{cfg: openAITestConfig(srv.URL)}).Complete("prompt")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenAIRequiresModel(t *testing.T) {
+	cfg := openAITestConfig("http://127.0.0.1:1")
+	cfg.Model = ""
+	if _, err := newProvider(cfg); err == nil {
+		t.Error("newProvider without a model succeeded for openai")
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	srv := openAIServer(t, func(r *http.Request, req openAIRequest) {
+		if !req.Stream || req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("request = %+v, want stream with usage", req)
+		}
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"{\\\"result\\\": \"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"true}\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":2}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	var deltas []string
+	text, usage, err := (openAIProvider{cfg: openAITestConfig(srv.URL)}).CompleteStream("prompt", func(s string) {
+		deltas = append(deltas, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != `{"result": true}` || usage != (Usage{InputTokens: 7, OutputTokens: 2}) {
+		t.Errorf("CompleteStream = %q, %+v", text, usage)
+	}
+	if len(deltas) != 2 || deltas[0] != `{"result": ` {
+		t.Errorf("deltas = %q", deltas)
+	}
+}
+
+func TestOpenAIStreamErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"status", http.StatusBadGateway, "upstream down", "502 Bad Gateway: upstream down"},
+		{"malformed chunk", http.StatusOK, "data: {\"choices\":\n\n", "model stream failed"},
+		{"empty", http.StatusOK, "data: [DONE]\n\n", "empty response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := openAIServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+			_, _, err := (openAIProvider{cfg: openAITestConfig(srv.URL)}).CompleteStream("prompt", func(string) {})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenAITimeout(t *testing.T) {
+	srv := openAIServer(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	cfg := openAITestConfig(srv.URL)
+	cfg.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	if _, _, err := (openAIProvider{cfg: cfg}).Complete("prompt"); err == nil {
+		t.Fatal("Complete returned without error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %v with a %v timeout", elapsed, cfg.Timeout)
+	}
+}
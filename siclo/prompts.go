@@ -0,0 +1,188 @@
+package siclo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const DefaultLanguage = "ru"
+
+//go:embed prompts
+var embeddedPrompts embed.FS
+
+var promptNames = []string{
+	"validate",
+	"validate_batch",
+	"appeal",
+	"answer",
+	"host",
+	"host_start",
+	"host_question",
+	"host_round",
+	"host_no_answer",
+	"host_end",
+}
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+	"json": jsonString,
+}
+
+// Prompts - набор шаблонов промптов для одного языка и пакета. Набор
+// передается в каждую функцию, которая обращается к модели, поэтому игры
+// на разных языках идут в одном процессе. nil - встроенные русские шаблоны.
+type Prompts struct {
+	language  string
+	templates map[string]*template.Template
+	// id - отпечаток языка и текстов шаблонов, часть ключа кеша вердиктов
+	id string
+}
+
+var defaultPrompts *Prompts
+
+func init() {
+	prompts, err := LoadPrompts("", DefaultLanguage, "")
+	if err != nil {
+		panic(err)
+	}
+	defaultPrompts = prompts
+}
+
+// DefaultPrompts возвращает встроенные русские шаблоны.
+func DefaultPrompts() *Prompts {
+	return defaultPrompts
+}
+
+// LoadPrompts собирает шаблоны для языка lang и пакета pkg. Каждый шаблон
+// ищется сначала в dir/<lang>/<pkg>/, потом в dir/<lang>/, потом среди
+// встроенных шаблонов языка и, наконец, среди встроенных русских.
+// dir и pkg могут быть пустыми.
+func LoadPrompts(dir, lang, pkg string) (*Prompts, error) {
+	lang = normalizeLanguage(lang)
+
+	p := &Prompts{
+		language:  lang,
+		templates: make(map[string]*template.Template),
+	}
+
+	h := sha256.New()
+	h.Write([]byte(lang))
+	for _, name := range promptNames {
+		text, source, err := findPrompt(dir, lang, pkg, name)
+		if err != nil {
+			return nil, err
+		}
+		h.Write([]byte{0})
+		h.Write([]byte(text))
+
+		tmpl, err := template.New(name).Funcs(promptFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prompt %s: %w", source, err)
+		}
+		p.templates[name] = tmpl
+	}
+	p.id = hex.EncodeToString(h.Sum(nil))
+
+	return p, nil
+}
+
+func (p *Prompts) Language() string {
+	if p == nil {
+		p = defaultPrompts
+	}
+	return p.language
+}
+
+// ID - отпечаток набора: у наборов с одинаковым языком и текстами шаблонов
+// он совпадает, даже если шаблоны лежат в разных папках.
+func (p *Prompts) ID() string {
+	if p == nil {
+		p = defaultPrompts
+	}
+	return p.id
+}
+
+func (p *Prompts) render(name string, data interface{}) (string, error) {
+	if p == nil {
+		p = defaultPrompts
+	}
+	tmpl, ok := p.templates[name]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt %s", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+func findPrompt(dir, lang, pkg, name string) (string, string, error) {
+	file := name + ".tmpl"
+
+	if dir != "" {
+		candidates := make([]string, 0, 2)
+		if pkg != "" {
+			candidates = append(candidates, filepath.Join(dir, lang, packageDirName(pkg), file))
+		}
+		candidates = append(candidates, filepath.Join(dir, lang, file))
+
+		for _, candidate := range candidates {
+			data, err := os.ReadFile(candidate)
+			if err == nil {
+				return string(data), candidate, nil
+			}
+			if !errors.Is(err, os.ErrNotExist) {
+				return "", "", fmt.Errorf("failed to read prompt %s: %w", candidate, err)
+			}
+		}
+	}
+
+	for _, l := range []string{lang, DefaultLanguage} {
+		source := path.Join("prompts", l, file)
+		if data, err := embeddedPrompts.ReadFile(source); err == nil {
+			return string(data), source, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("prompt %s not found", name)
+}
+
+// jsonString экранирует значение как JSON, чтобы текст игрока не мог
+// закрыть кавычку и дописать в запрос свои поля.
+func jsonString(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// normalizeLanguage превращает "ru-RU" или "EN_us" в "ru" и "en".
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if lang == "" {
+		return DefaultLanguage
+	}
+	return lang
+}
+
+// packageDirName - имя папки с промптами для пакета: название пакета
+// в нижнем регистре, слова через подчеркивание.
+func packageDirName(pkg string) string {
+	return strings.ReplaceAll(normalize(pkg), " ", "_")
+}
@@ -0,0 +1,43 @@
+package siclo
+
+import "fmt"
+
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+)
+
+// Provider - API модели. Anthropic и любые сервера, совместимые с
+// OpenAI chat completions (llama.cpp, vLLM, Ollama), реализуют его по-своему.
+type Provider interface {
+	// Complete отправляет промпт и возвращает текст ответа и потраченные токены.
+	Complete(prompt string) (string, Usage, error)
+	// CompleteStream работает как Complete, но отдает текст в onText по мере генерации.
+	CompleteStream(prompt string, onText StreamFunc) (string, Usage, error)
+}
+
+// newProvider выбирает провайдера по cfg.Provider. Пустое значение - Anthropic.
+func newProvider(cfg Config) (Provider, error) {
+	cfg, err := cfg.WithDefaults()
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.Provider {
+	case "", ProviderAnthropic:
+		return anthropicProvider{cfg: cfg}, nil
+	case ProviderOpenAI:
+		return openAIProvider{cfg: cfg}, nil
+	default:
+		return nil, fmt.Errorf("unknown model provider %q", cfg.Provider)
+	}
+}
+
+// complete отправляет один пользовательский промпт модели и возвращает текст
+// ответа и потраченные токены.
+func complete(prompt string) (string, Usage, error) {
+	provider, err := newProvider(currentConfig())
+	if err != nil {
+		return "", Usage{}, err
+	}
+	return provider.Complete(prompt)
+}
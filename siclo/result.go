@@ -1,6 +1,51 @@
 package siclo
 
+import "time"
+
 type ValidationResult struct {
-	Result        bool   `json:"result"`
+	Result bool `json:"result"`
+	// Clarify - ответ неполный, ведущий просит уточнить (например, назвать
+	// имя, а не только фамилию). Result при этом false.
+	Clarify       bool   `json:"clarify,omitempty"`
 	Justification string `json:"justification"`
+	Usage         Usage  `json:"-"`
+	Cached        bool   `json:"-"`
+	// Injection - ответ похож на попытку управлять моделью, поэтому он
+	// проверен строго, без модели.
+	Injection bool `json:"-"`
+	// Redacted - ведущий назвал ответ с карточки, и эти слова спрятаны.
+	Redacted bool `json:"-"`
+}
+
+type NPCAnswer struct {
+	Buzz       bool          `json:"buzz"`
+	Answer     string        `json:"answer"`
+	Confidence float64       `json:"confidence"`
+	Delay      time.Duration `json:"-"`
+	Usage      Usage         `json:"-"`
+}
+
+// Usage - токены, потраченные на один запрос к модели.
+// Для вердиктов из кеша она нулевая.
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		InputTokens:  u.InputTokens + other.InputTokens,
+		OutputTokens: u.OutputTokens + other.OutputTokens,
+	}
+}
+
+// Cost - стоимость в долларах по ценам из текущего Config.
+func (u Usage) Cost() float64 {
+	cfg := currentConfig()
+	return (float64(u.InputTokens)*cfg.InputPrice + float64(u.OutputTokens)*cfg.OutputPrice) / 1e6
+}
+
+type HostLine struct {
+	Text  string
+	Usage Usage
 }
@@ -0,0 +1,114 @@
+package siclo
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// StreamFunc получает очередной кусок реплики по мере генерации.
+type StreamFunc func(delta string)
+
+// completeStream работает как complete, но передает в onDelta текст строкового
+// поля field из JSON ответа модели, пока тот генерируется.
+func completeStream(prompt, field string, onDelta StreamFunc) (string, Usage, error) {
+	provider, err := newProvider(currentConfig())
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	extractor := newFieldStreamer(field)
+	return provider.CompleteStream(prompt, func(chunk string) {
+		if out := extractor.feed(chunk); out != "" {
+			onDelta(out)
+		}
+	})
+}
+
+// fieldStreamer достает значение строкового поля из JSON, который приходит
+// кусками, и отдает его уже раскодированным.
+type fieldStreamer struct {
+	start *regexp.Regexp
+	raw   string
+	pos   int
+	found bool
+	done  bool
+}
+
+func newFieldStreamer(field string) *fieldStreamer {
+	return &fieldStreamer{
+		start: regexp.MustCompile(`"` + regexp.QuoteMeta(field) + `"\s*:\s*"`),
+	}
+}
+
+// feed добавляет кусок ответа модели и возвращает новый текст поля.
+// Незаконченные escape-последовательности и символы UTF-8 ждут следующего куска.
+func (s *fieldStreamer) feed(chunk string) string {
+	s.raw += chunk
+	if s.done {
+		return ""
+	}
+
+	if !s.found {
+		loc := s.start.FindStringIndex(s.raw)
+		if loc == nil {
+			return ""
+		}
+		s.found = true
+		s.pos = loc[1]
+	}
+
+	var out strings.Builder
+	for s.pos < len(s.raw) {
+		rest := s.raw[s.pos:]
+
+		switch rest[0] {
+		case '"':
+			s.done = true
+			return out.String()
+
+		case '\\':
+			size := escapeSize(rest)
+			if size == 0 {
+				return out.String()
+			}
+			var decoded string
+			if err := json.Unmarshal([]byte(`"`+rest[:size]+`"`), &decoded); err == nil {
+				out.WriteString(decoded)
+			}
+			s.pos += size
+
+		default:
+			if !utf8.FullRuneInString(rest) {
+				return out.String()
+			}
+			_, size := utf8.DecodeRuneInString(rest)
+			out.WriteString(rest[:size])
+			s.pos += size
+		}
+	}
+
+	return out.String()
+}
+
+// escapeSize - длина escape-последовательности в начале s или 0, если она
+// еще не пришла целиком. Суррогатная пара \uXXXX\uXXXX берется вместе.
+func escapeSize(s string) int {
+	if len(s) < 2 {
+		return 0
+	}
+	if s[1] != 'u' {
+		return 2
+	}
+	if len(s) < 6 {
+		return 0
+	}
+	if (s[2] == 'd' || s[2] == 'D') && strings.IndexByte("89abAB", s[3]) >= 0 {
+		if len(s) < 12 {
+			return 0
+		}
+		return 12
+	}
+	return 6
+}
@@ -3,9 +3,9 @@ module siziph
 go 1.22.0
 
 require (
+	github.com/goldenpineappleofthesun/siziph v0.0.0
 	github.com/yeka/zip v0.0.0-20231116150916-03d6312748a9
 	golang.org/x/crypto v0.45.0
-    github.com/goldenpineappleofthesun/siziph v0.0.0
 )
 
 replace github.com/goldenpineappleofthesun/siziph => ../siziph